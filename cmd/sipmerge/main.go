@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+
+	"spycraft/lib/cdr"
+)
+
+type Config struct {
+	Collated string   `arg:"-c,--collated" help:"only merge this collation id"`
+	JSON     bool     `arg:"-j,--json" help:"output calls as json lines"`
+	Paths    []string `arg:"positional,required" help:"cdr files of each node"`
+}
+
+var config = Config{}
+
+func (Config) Description() string {
+	return "sipmerge - merge call legs from spycraft nodes"
+}
+
+func main() {
+	arg.MustParse(&config)
+
+	var records []*cdr.Record
+	for _, path := range config.Paths {
+		found, err := cdr.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, record := range found {
+			if len(config.Collated) == 0 || record.Collated == config.Collated {
+				records = append(records, record)
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, call := range cdr.Merge(records) {
+		if config.JSON {
+			if err := encoder.Encode(call); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+
+		fmt.Printf("%s %v legs=%d nodes=%s\n", call.Collated, call.Created.Local().Format("2006-01-02 15:04:05"), call.Legs, strings.Join(call.Nodes, ","))
+		call.Walk(func(hop *cdr.Hop, depth int) {
+			leg := hop.Record
+			fmt.Printf("%s%v/%v %s final=%d setup=%dms answer=%dms duration=%v nodes=%s\n",
+				strings.Repeat("  ", depth+1), leg.Endpoint, leg.Port, leg.CallID, leg.Final,
+				hop.Setup, hop.Answer, leg.Duration, strings.Join(hop.Nodes, ","))
+		})
+	}
+}
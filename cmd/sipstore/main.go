@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/alexflint/go-arg"
+
+	"spycraft/lib/archive"
+)
+
+type Config struct {
+	Store string `arg:"-s,--store" help:"spycraft message store"`
+	Pcap  string `arg:"-p,--pcap" help:"export messages to pcap file"`
+	List  bool   `arg:"-l,--list" help:"list stored calls"`
+	ID    string `arg:"positional" help:"collation or call id"`
+}
+
+var (
+	config     = Config{}
+	workingDir = "/var/lib/spycraft"
+)
+
+func (Config) Description() string {
+	return "sipstore - query sip messages stored by spycraft"
+}
+
+func main() {
+	arg.MustParse(&config)
+	if len(config.Store) == 0 {
+		config.Store = filepath.Join(workingDir, "messages")
+	}
+	if _, err := os.Stat(config.Store); err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := archive.OpenDisk(config.Store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	if config.List || len(config.ID) == 0 {
+		calls := store.Calls()
+		ids := make([]string, 0, len(calls))
+		for callid := range calls {
+			ids = append(ids, callid)
+		}
+		sort.Strings(ids)
+		for _, callid := range ids {
+			collated := calls[callid]
+			if len(collated) == 0 {
+				collated = "-"
+			}
+			fmt.Printf("%s %s\n", collated, callid)
+		}
+		return
+	}
+
+	messages, err := store.Call(config.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(messages) == 0 {
+		log.Fatalf("%s: no messages stored", config.ID)
+	}
+
+	if len(config.Pcap) == 0 {
+		if err := archive.WriteText(os.Stdout, messages); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	output, err := os.Create(config.Pcap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := archive.WritePcap(output, messages); err != nil {
+		log.Fatal(err)
+	}
+	if err := output.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
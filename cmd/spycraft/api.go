@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"spycraft/lib/cdr"
+	"spycraft/lib/service"
+)
+
+// LegView is an active leg as reported by the api
+type LegView struct {
+	Leg       string           `json:"leg"`
+	Collated  string           `json:"collated"`
+	CallID    string           `json:"callid"`
+	Trunk     string           `json:"trunk,omitempty"`
+	Incoming  bool             `json:"incoming"`
+	State     string           `json:"state"`
+	Caller    cdr.Party        `json:"caller"`
+	Called    cdr.Party        `json:"called"`
+	Class     string           `json:"class,omitempty"`
+	Codec     string           `json:"codec,omitempty"`
+	Endpoint  net.IP           `json:"endpoint"`
+	Port      uint16           `json:"port"`
+	Created   time.Time        `json:"created"`
+	Answered  time.Time        `json:"answered"`
+	Duration  service.Duration `json:"duration"`
+	Holds     int              `json:"holds,omitempty"`
+	Transfer  *cdr.Transfer    `json:"transfer,omitempty"`
+	Remaining service.Duration `json:"expires"` // till leg would be expired
+}
+
+// CallView is every leg known of a collated call
+type CallView struct {
+	Collated  string        `json:"collated"`
+	Active    []LegView     `json:"active"`
+	Completed []*cdr.Record `json:"completed"`
+}
+
+// NodeStatus reports the health of this spycraft node
+type NodeStatus struct {
+	Node      string           `json:"node"`
+	Host      net.IP           `json:"host"`
+	Port      uint16           `json:"port"`
+	Device    string           `json:"device"`
+	Started   time.Time        `json:"started"`
+	Uptime    service.Duration `json:"uptime"`
+	Legs      LegStats         `json:"legs"`
+	Channels  int              `json:"channels"`
+	Packets   int              `json:"packets_queued"`
+	Messages  int              `json:"messages_queued"`
+	Received  int              `json:"packets_received"`
+	Dropped   int              `json:"packets_dropped"`
+	Completed int              `json:"recent"` // records kept for queries
+}
+
+var (
+	queries = make(chan func())
+	recent  *cdr.Ring
+	started = time.Now()
+)
+
+// Query runs a function in the messages goroutine that owns the legs
+func Query(ctx context.Context, query func()) error {
+	done := make(chan struct{})
+	select {
+	case queries <- func() { query(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-done
+	return nil
+}
+
+func OpenRecent() {
+	if web.History > 0 && len(web.Listen) > 0 && web.Listen != "none" {
+		recent = cdr.NewRing(web.History)
+		sinks = append(sinks, recent)
+	}
+}
+
+func routeAPI() {
+	router.HandleFunc("GET /api/legs", getLegs)
+	router.HandleFunc("GET /api/calls/{collated}", getCall)
+	router.HandleFunc("GET /api/calls/{collated}/messages", getMessages)
+	router.HandleFunc("GET /api/calls/{collated}/{format}", getExport)
+	router.HandleFunc("GET /api/cdrs", getRecords)
+	router.HandleFunc("GET /api/status", getStatus)
+	router.HandleFunc("GET /api/events", getEvents)
+}
+
+// View describes an active leg as of a time
+func (leg *Leg) View(legid string, now time.Time) LegView {
+	view := LegView{
+		Leg:      legid,
+		Collated: leg.Collated,
+		CallID:   leg.CallID,
+		Trunk:    leg.Trunk,
+		Incoming: leg.Incoming,
+		State:    leg.Status(),
+		Caller:   leg.Caller,
+		Called:   leg.Called,
+		Class:    string(leg.Class),
+		Codec:    leg.Codec,
+		Endpoint: leg.Endpoint,
+		Port:     leg.Port,
+		Created:  leg.Created,
+		Answered: leg.Answered,
+		Holds:    leg.Holds,
+	}
+	if leg.Transfer != nil {
+		transfer := *leg.Transfer // encoded after the query returns
+		view.Transfer = &transfer
+	}
+	if leg.Connected {
+		view.Duration = service.Duration(now.Sub(leg.Answered))
+	}
+	if expires := leg.Expires(); !expires.IsZero() {
+		view.Remaining = service.Duration(expires.Sub(now))
+	}
+	return view
+}
+
+// Status summarizes leg states as one word
+func (leg *Leg) Status() string {
+	switch {
+	case !leg.Finished.IsZero():
+		return "ended"
+	case leg.Connected && leg.OnHold():
+		return "held"
+	case leg.Connected:
+		return "connected"
+	case !leg.EarlyMedia.IsZero():
+		return "early"
+	case leg.States[0].Request == Ring || leg.States[1].Request == Ring:
+		return "ringing"
+	}
+	return "setup"
+}
+
+func getLegs(w http.ResponseWriter, r *http.Request) {
+	trunk := r.URL.Query().Get("trunk")
+	var views []LegView
+	err := Query(r.Context(), func() {
+		views = make([]LegView, 0, len(legs))
+		now := lastSweep()
+		for legid, leg := range legs {
+			if len(trunk) == 0 || trunk == leg.Trunk {
+				views = append(views, leg.View(legid, now))
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].Created.Before(views[j].Created)
+	})
+	writeJSON(w, http.StatusOK, views)
+}
+
+func getCall(w http.ResponseWriter, r *http.Request) {
+	call := &CallView{Collated: r.PathValue("collated")}
+	err := Query(r.Context(), func() {
+		now := lastSweep()
+		for legid, leg := range legs {
+			if leg.Collated == call.Collated {
+				call.Active = append(call.Active, leg.View(legid, now))
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+	if recent != nil {
+		call.Completed = recent.Recent(&cdr.Filter{Collated: call.Collated}, 0)
+	}
+	if len(call.Active) == 0 && len(call.Completed) == 0 {
+		writeError(w, http.StatusNotFound, "call not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, call)
+}
+
+func getRecords(w http.ResponseWriter, r *http.Request) {
+	if recent == nil {
+		writeError(w, http.StatusNotFound, "no recent records kept")
+		return
+	}
+	query := r.URL.Query()
+	filter := &cdr.Filter{
+		Number: query.Get("number"),
+		Trunk:  query.Get("trunk"),
+		Status: query.Get("status"),
+	}
+	var err error
+	if filter.From, err = parseTime(query.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from")
+		return
+	}
+	if filter.To, err = parseTime(query.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to")
+		return
+	}
+	limit := 100
+	if value := query.Get("limit"); len(value) > 0 {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, recent.Recent(filter, limit))
+}
+
+func getStatus(w http.ResponseWriter, r *http.Request) {
+	status := &NodeStatus{
+		Node:     config.Name,
+		Host:     config.Host,
+		Port:     config.Port,
+		Device:   config.Device,
+		Started:  started,
+		Uptime:   service.Duration(time.Since(started)),
+		Packets:  len(packets),
+		Messages: len(messages),
+	}
+	err := Query(r.Context(), func() {
+		status.Legs = legStats
+		status.Legs.Active = len(legs)
+		if tracker != nil {
+			status.Channels = tracker.Current(nodeChannels)
+		}
+	})
+	if err != nil {
+		return
+	}
+	stats := captureStats()
+	status.Received = stats.PacketsReceived
+	status.Dropped = stats.PacketsDropped
+	if recent != nil {
+		status.Completed = recent.Len()
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// lastSweep is message time, which may lag wall clock in a quiet capture
+func lastSweep() time.Time {
+	if nextSweep.IsZero() {
+		return time.Now()
+	}
+	return nextSweep.Add(-time.Second)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		service.Debugf(2, "api write failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
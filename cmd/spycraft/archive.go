@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"spycraft/lib/archive"
+	"spycraft/lib/service"
+)
+
+type Storage struct {
+	Calls    int    `ini:"calls"`    // calls kept in memory
+	Messages int    `ini:"messages"` // messages kept per call
+	Path     string `ini:"path"`     // on disk store instead of memory
+	Age      int    `ini:"age"`      // hours kept on disk
+	Size     int64  `ini:"size"`     // megabytes kept on disk
+}
+
+// MessageView is a stored sip message as reported by the api
+type MessageView struct {
+	Time     time.Time `json:"time"`
+	CallID   string    `json:"callid"`
+	Incoming bool      `json:"incoming"`
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	Summary  string    `json:"summary"`
+	Text     string    `json:"text"`
+}
+
+var (
+	storage = Storage{
+		Calls:    1000,
+		Messages: 200,
+	}
+
+	stored    archive.Store
+	archiving chan func() // writes handed off from the messages goroutine
+	archived  sync.WaitGroup
+	closing   = make(map[string]time.Time) // call ids of ended legs till 64*T1
+)
+
+func OpenArchive() {
+	if len(storage.Path) > 0 && storage.Path != "none" {
+		disk, err := archive.OpenDisk(storage.Path)
+		if err != nil {
+			service.Error(err)
+			return
+		}
+		disk.Age = time.Duration(storage.Age) * time.Hour
+		disk.Size = storage.Size * 1024 * 1024
+		stored = disk
+		service.Infof("storing messages in %s", storage.Path)
+	} else if storage.Calls > 0 {
+		stored = archive.NewMemory(storage.Calls, storage.Messages)
+	}
+	if stored != nil {
+		archiving = make(chan func(), pipelines.Archive)
+		archived.Add(1)
+		go Archiver()
+	}
+}
+
+// Retention prunes the on disk store by age and size each minute
+func Retention(ctx context.Context) {
+	disk, ok := stored.(*archive.Disk)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if removed, err := disk.Prune(time.Now()); err != nil {
+			service.Error(err)
+		} else if removed > 0 {
+			service.Infof("removed %d hours of stored messages", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func CloseArchive() {
+	if stored != nil {
+		close(archiving)
+		archived.Wait()
+		stored.Close()
+	}
+}
+
+// Archiver writes stored messages so disk latency does not hold up sip
+func Archiver() {
+	defer archived.Done()
+	for write := range archiving {
+		write()
+	}
+}
+
+// Archive keeps a copy of a sip message for ladders and troubleshooting
+func Archive(message *SIPMessage, callid []byte) {
+	if stored == nil {
+		return
+	}
+	local := net.JoinHostPort(config.Host.String(), strconv.Itoa(int(config.Port)))
+	remote := net.JoinHostPort(message.RemoteIP.String(), strconv.Itoa(int(message.RemotePort)))
+	entry := &archive.Message{
+		Time:     message.Timestamp,
+		CallID:   string(callid),
+		Incoming: message.Incoming,
+		Source:   local,
+		Target:   remote,
+		Data:     append([]byte(nil), message.Data...),
+	}
+	if message.Incoming {
+		entry.Source, entry.Target = remote, local
+	}
+	archiving <- func() {
+		if err := stored.Add(entry); err != nil {
+			service.Error(err)
+		}
+	}
+}
+
+// Archived stores a message of a tracked leg and indexes it by collation
+func (leg *Leg) Archived(message *SIPMessage) {
+	if stored != nil {
+		Archive(message, []byte(leg.CallID))
+		collated, callid := leg.Collated, leg.CallID
+		archiving <- func() { stored.Link(collated, callid) }
+	}
+}
+
+// Linger keeps storing messages of an ended leg's call id, as the final
+// response, the 487 and ack of a cancel, or the ack of a failed invite
+// arrive after the leg is gone
+func (leg *Leg) Linger(when time.Time) {
+	if stored != nil {
+		closing[leg.CallID] = when.Add(timerB)
+	}
+}
+
+// Closing stores a message of a call whose legs recently ended
+func Closing(message *SIPMessage, callid []byte) {
+	expires, found := closing[string(callid)]
+	if found && !message.Timestamp.After(expires) {
+		Archive(message, callid)
+	}
+}
+
+// ExpireClosing forgets call ids that ended more than 64*T1 ago
+func ExpireClosing(now time.Time) {
+	for callid, expires := range closing {
+		if now.After(expires) {
+			delete(closing, callid)
+		}
+	}
+}
+
+func getMessages(w http.ResponseWriter, r *http.Request) {
+	messages := callMessages(w, r)
+	if messages == nil {
+		return
+	}
+	views := make([]MessageView, 0, len(messages))
+	for _, message := range messages {
+		views = append(views, MessageView{
+			Time:     message.Time,
+			CallID:   message.CallID,
+			Incoming: message.Incoming,
+			Source:   message.Source,
+			Target:   message.Target,
+			Summary:  message.Summary(),
+			Text:     string(message.Data),
+		})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// getExport writes stored messages of a call as pcap or text
+func getExport(w http.ResponseWriter, r *http.Request) {
+	messages := callMessages(w, r)
+	if messages == nil {
+		return
+	}
+	name := "call"
+	if len(messages) > 0 {
+		name = safeName(messages[0].CallID)
+	}
+	var err error
+	switch r.PathValue("format") {
+	case "pcap":
+		w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pcap"`, name))
+		err = archive.WritePcap(w, messages)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = archive.WriteText(w, messages)
+	default:
+		writeError(w, http.StatusNotFound, "unknown export format")
+		return
+	}
+	if err != nil {
+		service.Debugf(2, "export failed: %v", err)
+	}
+}
+
+// safeName replaces characters of an id not fit for a file name
+func safeName(id string) string {
+	return strings.Map(func(c rune) rune {
+		if strings.ContainsRune(`"\/;:`, c) {
+			return '_'
+		}
+		return c
+	}, id)
+}
+
+func callMessages(w http.ResponseWriter, r *http.Request) []*archive.Message {
+	if stored == nil {
+		writeError(w, http.StatusNotFound, "messages not stored")
+		return nil
+	}
+	messages, err := stored.Call(r.PathValue("collated"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if len(messages) == 0 {
+		writeError(w, http.StatusNotFound, "no messages for call")
+		return nil
+	}
+	return messages
+}
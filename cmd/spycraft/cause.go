@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+)
+
+// Disconnected records the side that ended a leg and the cause it gave,
+// mapping the sip status and warning to q.850 when no reason header says
+// otherwise
+func (leg *Leg) Disconnected(state *State, by string, status, warning int, reasons [][]byte) {
+	if leg.Disconnect != nil {
+		return
+	}
+	disconnect := &cdr.Disconnect{
+		Side: leg.Side(state),
+		By:   by,
+		SIP:  status,
+	}
+	for _, reason := range reasons {
+		protocol, cause, text := byteshark.ParseReason(reason)
+		switch {
+		case byteshark.MatchKeyword(protocol, []byte("q.850")) && disconnect.Q850 == 0:
+			disconnect.Q850 = cause
+			disconnect.Text = string(text)
+		case byteshark.MatchKeyword(protocol, []byte("sip")) && disconnect.SIP == 0:
+			disconnect.SIP = cause
+		}
+	}
+	if disconnect.Q850 == 0 {
+		disconnect.Q850 = cdr.Q850Warning(disconnect.SIP, warning)
+		disconnect.Mapped = true
+	}
+	if len(disconnect.Text) == 0 {
+		disconnect.Text = cdr.CauseText(disconnect.Q850)
+	}
+	leg.Disconnect = disconnect
+}
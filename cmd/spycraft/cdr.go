@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"spycraft/lib/cdr"
+	"spycraft/lib/events"
+	"spycraft/lib/service"
+)
+
+type Records struct {
+	Path string `ini:"path"`
+}
+
+var (
+	records = Records{}
+	sinks   []cdr.Sink
+)
+
+func OpenSinks() {
+	if len(records.Path) == 0 {
+		records.Path = logPrefix + "/spycraft.cdr"
+	}
+	if records.Path == "none" || records.Path == "no" {
+		return
+	}
+	sink, err := cdr.NewFileSink(records.Path)
+	if err != nil {
+		service.Error(err)
+		return
+	}
+	sinks = append(sinks, sink)
+}
+
+func CloseSinks() {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+	sinks = nil
+}
+
+func Report(record interface{}) {
+	for _, sink := range sinks {
+		if err := sink.Write(record); err != nil {
+			service.Error(err)
+		}
+	}
+}
+
+// End reports a finished leg and stops tracking it
+func End(legid string, leg *Leg) {
+	leg.Release(leg.Finished)
+	leg.Emit(events.Ended, leg.Finished)
+	leg.StopRecording()
+	leg.Linger(leg.Finished)
+	record := leg.Record()
+	Report(record)
+	Measure(record)
+	Scored(record)
+	releaseDialogs(leg)
+	streams.Release(legid)
+	if correlator != nil {
+		correlator.Release(legid)
+	}
+	delete(legs, legid)
+	legStats.Completed++
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"time"
+
+	"spycraft/lib/cdr"
+	"spycraft/lib/channels"
+	"spycraft/lib/service"
+)
+
+type Channels struct {
+	Interval int `ini:"interval"` // seconds of each peak interval
+	Limit    int `ini:"limit"`    // channels of the node, 0 for none
+	Warn     int `ini:"warn"`     // percent of a limit to alert at
+}
+
+var (
+	channeling = Channels{
+		Interval: 900,
+		Warn:     80,
+	}
+
+	tracker      *channels.Tracker
+	alerted      = make(map[channels.Key]bool)
+	nodeChannels = channels.Key{}
+)
+
+func OpenChannels() {
+	interval := time.Duration(channeling.Interval) * time.Second
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	tracker = channels.NewTracker(interval)
+}
+
+// Seize counts a leg as a channel in use from invite until it ends
+func (leg *Leg) Seize(when time.Time) {
+	if tracker == nil || leg.Channel != nil {
+		return
+	}
+	direction := channels.Outgoing
+	if leg.Incoming {
+		direction = channels.Incoming
+	}
+	leg.Channel = &channels.Key{Trunk: leg.Trunk, Direction: direction}
+	for _, key := range channelKeys(leg.Channel) {
+		alert(key, tracker.Seize(key, when))
+	}
+}
+
+// Release frees the channel of a leg
+func (leg *Leg) Release(when time.Time) {
+	if tracker == nil || leg.Channel == nil {
+		return
+	}
+	for _, key := range channelKeys(leg.Channel) {
+		alert(key, tracker.Release(key, when))
+	}
+	leg.Channel = nil
+}
+
+// Peaks writes interval records of peak channels as intervals end
+func Peaks(now time.Time) {
+	if tracker == nil {
+		return
+	}
+	for _, usage := range tracker.Roll(now) {
+		Report(&cdr.Interval{
+			Type:      cdr.IntervalRecord,
+			Node:      config.Name,
+			Trunk:     usage.Trunk,
+			Direction: usage.Direction,
+			Start:     usage.Start,
+			End:       usage.End,
+			Peak:      usage.Peak,
+			Peaked:    usage.Peaked,
+			Current:   usage.Current,
+			Limit:     channelLimit(usage.Key),
+		})
+	}
+}
+
+// keys of the node, node direction, and trunk and trunk direction
+func channelKeys(channel *channels.Key) []channels.Key {
+	keys := []channels.Key{{}, {Direction: channel.Direction}}
+	if len(channel.Trunk) > 0 {
+		keys = append(keys, channels.Key{Trunk: channel.Trunk}, *channel)
+	}
+	return keys
+}
+
+func channelLimit(key channels.Key) int {
+	if len(key.Direction) > 0 {
+		return 0
+	}
+	if len(key.Trunk) == 0 {
+		return channeling.Limit
+	}
+	if found := trunks.Find(key.Trunk); found != nil {
+		return found.Channels
+	}
+	return 0
+}
+
+// alert once when channels in use approach a limit, again once clear
+func alert(key channels.Key, current int) {
+	limit := channelLimit(key)
+	if limit <= 0 {
+		return
+	}
+	name := "node"
+	if len(key.Trunk) > 0 {
+		name = "trunk " + key.Trunk
+	}
+	threshold := (limit*channeling.Warn + 99) / 100
+	if current >= threshold && !alerted[key] {
+		alerted[key] = true
+		service.Warnf("%s at %d of %d channels", name, current, limit)
+	} else if current < threshold && alerted[key] {
+		delete(alerted, key)
+		service.Noticef("%s down to %d of %d channels", name, current, limit)
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/collate"
+	"spycraft/lib/service"
+)
+
+type Collation struct {
+	Enabled   bool     `ini:"enabled"`
+	Headers   []string `ini:"headers" delim:","` // b2bua original call id headers
+	Window    int      `ini:"window"`            // seconds between related invites
+	Threshold int      `ini:"threshold"`
+
+	// evidence weights
+	Original  int `ini:"original"`
+	SessionID int `ini:"session"`
+	Media     int `ini:"media"`
+	Diverted  int `ini:"diverted"`
+	Caller    int `ini:"caller"`
+	Proximity int `ini:"proximity"`
+}
+
+var (
+	collation = Collation{
+		Enabled:   true,
+		Headers:   []string{"x-original-call-id"},
+		Threshold: unset,
+		Original:  unset,
+		SessionID: unset,
+		Media:     unset,
+		Diverted:  unset,
+		Caller:    unset,
+		Proximity: unset,
+	}
+
+	correlator *collate.Engine
+)
+
+const unset = -1 // weight not in config, keep the default
+
+func OpenCollation() {
+	rules := collate.DefaultRules()
+	if collation.Window > 0 {
+		rules.Window = time.Duration(collation.Window) * time.Second
+	}
+	setWeight(&rules.Threshold, collation.Threshold)
+	setWeight(&rules.Original, collation.Original)
+	setWeight(&rules.SessionID, collation.SessionID)
+	setWeight(&rules.Media, collation.Media)
+	setWeight(&rules.Diverted, collation.Diverted)
+	setWeight(&rules.Caller, collation.Caller)
+	setWeight(&rules.Proximity, collation.Proximity)
+	correlator = collate.NewEngine(rules)
+}
+
+// OriginalHeader tests for a configured b2bua call id header
+func OriginalHeader(key []byte) bool {
+	for _, name := range collation.Headers {
+		if byteshark.MatchHeader(key, strings.TrimSpace(name), "") {
+			return true
+		}
+	}
+	return false
+}
+
+// Evidence gathers correlation facts from the initial invite of a leg
+func (leg *Leg) Evidence(legid string, original []byte, sdp *byteshark.SDP) *collate.Evidence {
+	evidence := &collate.Evidence{
+		Key:       legid,
+		CallID:    leg.CallID,
+		Created:   leg.Created,
+		Original:  string(original),
+		SessionID: leg.SessionID,
+		Caller:    leg.Caller.Number,
+		Called:    leg.Called.Number,
+	}
+	for _, redirect := range leg.Redirects {
+		evidence.Diverted = append(evidence.Diverted, redirect.Number)
+	}
+
+	if sdp != nil {
+		if audio := sdp.Audio(); audio != nil {
+			address := sdp.Address
+			if audio.Address != nil {
+				address = audio.Address
+			}
+			if address != nil && !address.IsUnspecified() {
+				evidence.Media = fmt.Sprintf("%v/%v", address, audio.Port)
+			}
+		}
+	}
+	return evidence
+}
+
+// Correlate collates a leg we sent with the leg a b2bua continued
+func (leg *Leg) Correlate(evidence *collate.Evidence) {
+	if !collation.Enabled {
+		return
+	}
+	found, score := correlator.Match(evidence)
+	if found == nil {
+		return
+	}
+	arrived := legs[found.Key]
+	if arrived == nil {
+		return
+	}
+	if len(arrived.Collated) == 0 {
+		arrived.Collated = arrived.CollationKey(nil)
+	}
+	leg.Collated = arrived.Collated
+	service.Infof("collated leg %v/%v on %s with score %d", leg.Endpoint, leg.Port, leg.Collated, score)
+}
+
+// Identify learns rfc 7989 session uuids of both ends of a leg, the
+// inviter's only from requests it sends and the other from the rest
+func (leg *Leg) Identify(session []byte, inviter bool) {
+	uuid, remote := byteshark.ParseSessionID(session)
+	sender := strings.ToLower(string(uuid))
+	peer := strings.ToLower(string(remote))
+	if inviter {
+		if len(leg.SessionID) == 0 {
+			leg.SessionID = sender
+		}
+		if len(leg.SessionRemote) == 0 && peer != leg.SessionID {
+			leg.SessionRemote = peer
+		}
+		return
+	}
+	if len(leg.SessionRemote) == 0 && sender != leg.SessionID {
+		leg.SessionRemote = sender
+	}
+}
+
+// CollationKey prefers session id, then x-collateid, then call id
+func (leg *Leg) CollationKey(collateid []byte) string {
+	if len(leg.SessionID) > 0 {
+		return leg.SessionID
+	}
+	if len(collateid) > 0 {
+		return string(collateid)
+	}
+	return leg.CallID
+}
+
+// setWeight overrides a default, where 0 turns the evidence off
+func setWeight(weight *int, value int) {
+	if value >= 0 {
+		*weight = value
+	}
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"spycraft/lib/service"
+)
+
+type DialogState int
+
+// Dialog is a sip dialog of a leg, identified by call id and both tags
+type Dialog struct {
+	CallID    string
+	LocalTag  string // tag of our host side
+	RemoteTag string // tag of the remote side
+	State     DialogState
+	Status    int // last response establishing the dialog
+	Leg       *Leg
+	Created   time.Time
+	Updated   time.Time
+
+	// rfc 4028 session timer
+	Interval  time.Duration
+	Refresher *State // slot expected to refresh
+	Refreshed time.Time
+	Refreshes int
+	Deadline  time.Time // refresh missed after this
+
+	// rfc 3262 reliable provisionals and early updates
+	RSeq     uint32 // last reliable provisional
+	Pracked  uint32 // last rseq acknowledged
+	Reliable int
+	Pracks   int
+	Updates  int
+}
+
+const (
+	Early DialogState = iota
+	Confirmed
+	Terminated
+)
+
+var dialogs = make(map[string]*Dialog)
+
+func DialogID(callid, local, remote []byte) string {
+	return fmt.Sprintf("%s/%s/%s", callid, local, remote)
+}
+
+// Early creates or refreshes an early dialog from a provisional response
+func (leg *Leg) Early(dialogid string, event *LegEvent, local, remote []byte) *Dialog {
+	dialog := dialogs[dialogid]
+	if dialog == nil {
+		dialog = &Dialog{
+			CallID:    leg.CallID,
+			LocalTag:  string(local),
+			RemoteTag: string(remote),
+			State:     Early,
+			Leg:       leg,
+			Created:   event.Timestamp,
+		}
+		dialogs[dialogid] = dialog
+		leg.Dialogs = append(leg.Dialogs, dialog)
+		if len(leg.Dialogs) > 1 {
+			service.Infof("forked leg %v/%v on %s with %d dialogs", leg.Endpoint, leg.Port, leg.Collated, len(leg.Dialogs))
+		}
+	}
+	dialog.Status = event.Status
+	dialog.Updated = event.Timestamp
+	return dialog
+}
+
+// Confirm makes a dialog confirmed, the first one to do so wins the leg
+func (leg *Leg) Confirm(dialogid string, event *LegEvent, local, remote []byte) *Dialog {
+	dialog := leg.Early(dialogid, event, local, remote)
+	if dialog.State != Early {
+		return dialog
+	}
+	dialog.State = Confirmed
+	if leg.Dialog != nil {
+		return dialog // late 2xx from another fork, expect a bye for it
+	}
+
+	leg.Dialog = dialog
+	for _, other := range leg.Dialogs {
+		if other.State == Early {
+			other.State = Terminated
+			other.Updated = event.Timestamp
+		}
+	}
+	return dialog
+}
+
+// Terminate ends all remaining dialogs of a leg
+func (leg *Leg) Terminate(when time.Time) {
+	for _, dialog := range leg.Dialogs {
+		if dialog.State != Terminated {
+			dialog.State = Terminated
+			dialog.Updated = when
+		}
+	}
+}
+
+// Stray tests if a dialog is a losing fork of the leg
+func (leg *Leg) Stray(dialog *Dialog) bool {
+	return dialog != nil && leg.Dialog != nil && dialog != leg.Dialog
+}
+
+func releaseDialogs(leg *Leg) {
+	for _, dialog := range leg.Dialogs {
+		delete(dialogs, DialogID([]byte(dialog.CallID), []byte(dialog.LocalTag), []byte(dialog.RemoteTag)))
+	}
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"spycraft/lib/byteshark"
+	"spycraft/lib/events"
+	"spycraft/lib/service"
+)
+
+// Provisional tracks alerting, early media, and reliable provisionals
+func (leg *Leg) Provisional(dialog *Dialog, event *LegEvent, rseq []byte, media bool) {
+	if leg.Connected || event.Status < 180 {
+		return
+	}
+	if leg.Alerted.IsZero() {
+		leg.Alerted = event.Timestamp
+		service.Debugf(3, "post dial delay %v for %s", leg.Alerted.Sub(leg.Created), leg.CallID)
+		leg.Emit(events.Ringing, event.Timestamp)
+	}
+	if media && leg.EarlyMedia.IsZero() {
+		leg.EarlyMedia = event.Timestamp
+		service.Debugf(3, "early media for %s", leg.CallID)
+	}
+
+	sequence := uint32(byteshark.ParseDelta(rseq))
+	if dialog == nil || sequence == 0 {
+		return
+	}
+	if sequence > dialog.RSeq {
+		dialog.RSeq = sequence
+		dialog.Reliable++
+	}
+}
+
+// Prack acknowledges a reliable provisional of an early dialog
+func (leg *Leg) Prack(dialog *Dialog, event *LegEvent, rack []byte) {
+	rseq, cseq, method := byteshark.ParseRAck(rack)
+	if dialog == nil || rseq == 0 || !byteshark.MatchKeyword(method, []byte("invite")) {
+		return
+	}
+	if rseq > dialog.Pracked {
+		dialog.Pracked = rseq
+		dialog.Pracks++
+	}
+	service.Debugf(3, "prack %d for invite %d on %s", rseq, cseq, leg.CallID)
+}
+
+// EarlyUpdate accounts for an answered update offer in an early dialog
+func (leg *Leg) EarlyUpdate(dialog *Dialog, event *LegEvent) {
+	if dialog == nil {
+		return
+	}
+	dialog.Updates++
+	if leg.EarlyMedia.IsZero() {
+		leg.EarlyMedia = event.Timestamp
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"spycraft/lib/events"
+	"spycraft/lib/service"
+)
+
+const heartbeat = 15 * time.Second
+
+var broker *events.Broker
+
+func OpenEvents() {
+	if web.Events > 0 && len(web.Listen) > 0 && web.Listen != "none" {
+		broker = events.NewBroker(web.Events)
+	}
+}
+
+// Emit publishes a leg state change to event stream subscribers
+func (leg *Leg) Emit(kind string, when time.Time) {
+	if broker == nil {
+		return
+	}
+	event := &events.Event{
+		Type:       kind,
+		Time:       when,
+		Node:       config.Name,
+		Leg:        leg.ID,
+		Collated:   leg.Collated,
+		CallID:     leg.CallID,
+		Trunk:      leg.Trunk,
+		Incoming:   leg.Incoming,
+		Caller:     leg.Caller,
+		Called:     leg.Called,
+		Status:     leg.Final,
+		Disconnect: leg.Disconnect,
+	}
+	if leg.Transfer != nil {
+		transfer := *leg.Transfer
+		event.Transfer = &transfer
+	}
+	if leg.Connected {
+		end := when
+		if !leg.Finished.IsZero() {
+			end = leg.Finished
+		}
+		event.Duration = service.Duration(end.Sub(leg.Answered))
+	}
+	broker.Publish(event)
+}
+
+// getEvents streams events as server sent events, resuming after the
+// Last-Event-ID header or since parameter
+func getEvents(w http.ResponseWriter, r *http.Request) {
+	if broker == nil {
+		writeError(w, http.StatusNotFound, "event stream disabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	query := r.URL.Query()
+	filter := events.Filter{
+		Trunk:     query.Get("trunk"),
+		Number:    query.Get("number"),
+		Direction: query.Get("direction"),
+	}
+	cursor := r.Header.Get("Last-Event-ID")
+	if len(cursor) == 0 {
+		cursor = query.Get("since")
+	}
+	var since uint64
+	if len(cursor) > 0 {
+		var err error
+		if since, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	subscriber, backlog := broker.Subscribe(filter, since)
+	defer broker.Unsubscribe(subscriber)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range backlog {
+		if writeEvent(w, event) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, open := <-subscriber.Events:
+			if !open {
+				return // fell behind, client reconnects with last id
+			}
+			if writeEvent(w, event) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
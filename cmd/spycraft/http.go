@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"spycraft/lib/service"
+)
+
+type Web struct {
+	Listen  string `ini:"listen"`  // address:port, none if empty
+	History int    `ini:"history"` // completed legs kept for queries
+	Events  int    `ini:"events"`  // events kept for stream resume
+}
+
+var (
+	web = Web{
+		History: 1000,
+		Events:  4096,
+	}
+	router = http.NewServeMux()
+)
+
+// Serve runs the http server for metrics, the api, and ui until cancelled
+func Serve(ctx context.Context) {
+	if len(web.Listen) == 0 || web.Listen == "none" {
+		return
+	}
+	router.Handle("GET /metrics", registry)
+	routeAPI()
+	routeUI()
+	server := &http.Server{
+		Addr:              web.Listen,
+		Handler:           router,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	go func() {
+		service.Infof("serving http on %s", web.Listen)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			service.Error(err)
+		}
+	}()
+}
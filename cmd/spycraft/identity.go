@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"bytes"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+)
+
+// Parties extracts calling, called, and redirecting parties from an invite
+func (leg *Leg) Parties(uri, from, to, asserted, rpid, preferred, privacy []byte, diversions, histories [][]byte) {
+	// asserted identity is trusted most, from header least
+	caller := from
+	for _, value := range [][]byte{preferred, rpid, asserted} {
+		if len(byteshark.ParseURIUser(byteshark.ParseURI(value))) > 0 {
+			caller = value
+		}
+	}
+	leg.Caller = party(caller)
+	if len(leg.Caller.Name) == 0 {
+		leg.Caller.Name = party(from).Name
+	}
+	leg.Caller.Private = private(privacy, rpid, from)
+
+	leg.Called = party(to)
+	if number := byteshark.ParseURIUser(uri); len(number) > 0 {
+		leg.Called.Number = byteshark.NormalizeUser(number)
+		leg.Called.URI = string(uri)
+	}
+
+	// diversion is newest first, history-info oldest first
+	leg.Redirects = nil
+	for pos := len(diversions) - 1; pos >= 0; pos-- {
+		redirect := party(diversions[pos])
+		if len(redirect.Number) > 0 {
+			leg.Redirects = append(leg.Redirects, cdr.Redirect{
+				Number: redirect.Number,
+				Reason: string(byteshark.ParseParam(diversions[pos], "reason")),
+			})
+		}
+	}
+	if len(leg.Redirects) == 0 && len(histories) > 1 {
+		for pos, entry := range histories[:len(histories)-1] {
+			redirect := party(entry)
+			if len(redirect.Number) == 0 {
+				continue
+			}
+			reason := byteshark.ParseParam(histories[pos+1], "cause")
+			if len(reason) == 0 {
+				reason = byteshark.ParseParam(byteshark.ParseURI(histories[pos+1]), "cause")
+			}
+			leg.Redirects = append(leg.Redirects, cdr.Redirect{
+				Number: redirect.Number,
+				Reason: string(reason),
+			})
+		}
+	}
+}
+
+// Original is the first number called before any redirection
+func (leg *Leg) Original() string {
+	if len(leg.Redirects) > 0 {
+		return leg.Redirects[0].Number
+	}
+	return ""
+}
+
+func party(value []byte) cdr.Party {
+	uri, _ := byteshark.SplitURIHeaders(byteshark.ParseURI(value))
+	result := cdr.Party{
+		Name: string(byteshark.ParseDisplayName(value)),
+		URI:  string(uri),
+	}
+	if user := byteshark.ParseURIUser(uri); len(user) > 0 {
+		result.Number = byteshark.NormalizeUser(user)
+	}
+	return result
+}
+
+// private tests rfc 3323 privacy, rpid privacy, or an anonymous from
+func private(privacy, rpid, from []byte) bool {
+	for _, value := range bytes.Split(privacy, []byte(";")) {
+		value = bytes.ToLower(bytes.TrimSpace(value))
+		if bytes.Equal(value, []byte("id")) || bytes.Equal(value, []byte("header")) || bytes.Equal(value, []byte("user")) {
+			return true
+		}
+	}
+	if level := byteshark.ParseParam(rpid, "privacy"); len(level) > 0 && !bytes.EqualFold(level, []byte("off")) {
+		return true
+	}
+	return bytes.Contains(bytes.ToLower(from), []byte("anonymous"))
+}
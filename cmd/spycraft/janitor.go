@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"spycraft/lib/cdr"
+	"spycraft/lib/service"
+)
+
+type Limits struct {
+	Duration int `ini:"duration"` // max seconds of connected call
+	Legs     int `ini:"legs"`     // max legs tracked at once
+}
+
+type LegStats struct {
+	Active    int    `json:"active"`
+	Peak      int    `json:"peak"`
+	Created   uint64 `json:"created"`
+	Completed uint64 `json:"completed"`
+	Expired   uint64 `json:"expired"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+// sip timers used to expire legs that never complete
+const (
+	timerT1 = 500 * time.Millisecond
+	timerB  = 64 * timerT1    // invite with no response
+	timerC  = 3 * time.Minute // invite with provisional but no final
+)
+
+var (
+	limits = Limits{
+		Duration: 21600,
+		Legs:     65536,
+	}
+
+	legStats  LegStats
+	nextSweep time.Time
+)
+
+// Expires returns when a leg should be considered lost
+func (leg *Leg) Expires() time.Time {
+	if !leg.Connected {
+		if leg.Responded.IsZero() {
+			return leg.Updated.Add(timerB)
+		}
+		return leg.Responded.Add(timerC)
+	}
+	if leg.Dialog != nil && !leg.Dialog.Deadline.IsZero() {
+		return leg.Dialog.Deadline
+	}
+	if limits.Duration > 0 {
+		return leg.Answered.Add(time.Duration(limits.Duration) * time.Second)
+	}
+	return time.Time{}
+}
+
+// Sweep expires stale legs, driven by message timestamps so that scans
+// of pcap files age legs the same way live capture does
+func Sweep(now time.Time) {
+	if now.Before(nextSweep) {
+		return
+	}
+	nextSweep = now.Add(time.Second)
+	ExpireReferrals(now)
+	ExpireClosing(now)
+	for legid, leg := range legs {
+		expires := leg.Expires()
+		if expires.IsZero() || !now.After(expires) {
+			continue
+		}
+
+		leg.Expired = cdr.TimedOut
+		if leg.Dialog != nil && expires.Equal(leg.Dialog.Deadline) {
+			leg.Expired = cdr.SessionExpired
+			leg.Finish(expires)
+		} else if leg.Connected {
+			leg.Finish(leg.Updated)
+		} else {
+			leg.Final = 408
+			leg.Finish(expires)
+		}
+		leg.Disconnected(nil, cdr.ByExpiry, 408, 0, nil)
+		service.Infof("expired leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+		legStats.Expired++
+		End(legid, leg)
+	}
+	legStats.Active = len(legs)
+	Publish()
+	service.Debugf(4, "sweep legs active=%d peak=%d expired=%d rejected=%d", legStats.Active, legStats.Peak, legStats.Expired, legStats.Rejected)
+}
+
+// Admit checks the hard cap before tracking another leg
+func Admit() bool {
+	if limits.Legs > 0 && len(legs) >= limits.Legs {
+		legStats.Rejected++
+		return false
+	}
+	legStats.Created++
+	if len(legs)+1 > legStats.Peak {
+		legStats.Peak = len(legs) + 1
+	}
+	return true
+}
+
+// Janitor feeds timer ticks to messages so idle live capture still expires
+func Janitor(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			select {
+			case messages <- &SIPMessage{Timestamp: now}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
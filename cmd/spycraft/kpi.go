@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"spycraft/lib/cdr"
+	"spycraft/lib/channels"
+	"spycraft/lib/kpi"
+	"spycraft/lib/service"
+)
+
+type Statistics struct {
+	Short  int `ini:"short"`  // seconds an answered call is short
+	Prefix int `ini:"prefix"` // digits of e164 destination prefix
+	Export int `ini:"export"` // seconds between kpi records, 0 for none
+}
+
+var (
+	statistics = Statistics{
+		Short:  6,
+		Prefix: 4,
+	}
+
+	kpis        *kpi.Engine
+	nextCollect time.Time
+	nextPrune   time.Time
+	nextExport  time.Time
+)
+
+func OpenStatistics() {
+	kpis = kpi.NewEngine(time.Duration(statistics.Short) * time.Second)
+}
+
+// Measure counts a finished leg by node, trunk, and destination prefix,
+// each keyed by direction so a b2bua call is one attempt of each
+func Measure(record *cdr.Record) {
+	if kpis == nil {
+		return
+	}
+	attempt := kpi.Attempt{
+		Answered: record.Connected,
+		Alerted:  record.PostDial > 0,
+		Duration: time.Duration(record.Duration),
+		PostDial: time.Duration(record.PostDial) * time.Millisecond,
+	}
+	if record.Disconnect != nil {
+		attempt.Effective = cdr.UserCause(record.Disconnect.Q850)
+	}
+
+	direction := "/" + channels.Outgoing
+	if record.Incoming {
+		direction = "/" + channels.Incoming
+	}
+	keys := []string{"node/" + record.Node + direction}
+	if len(record.Trunk) > 0 {
+		keys = append(keys, "trunk/"+record.Trunk+direction)
+	}
+	if prefix := Prefix(record.Called.E164); len(prefix) > 0 {
+		keys = append(keys, "prefix/"+prefix+direction)
+	}
+	kpis.Add(record.Finished, attempt, keys...)
+}
+
+// Prefix is the leading digits of an e164 number that kpi groups by
+func Prefix(e164 string) string {
+	digits := strings.TrimPrefix(e164, "+")
+	if statistics.Prefix <= 0 || len(digits) == 0 {
+		return ""
+	}
+	if len(digits) > statistics.Prefix {
+		digits = digits[:statistics.Prefix]
+	}
+	return digits
+}
+
+// Collect advances kpi windows by message time, pruning idle keys and
+// writing kpi records to the cdr sinks when export is enabled
+func Collect(now time.Time) {
+	if kpis == nil || now.Before(nextCollect) {
+		return
+	}
+	nextCollect = now.Add(time.Second)
+	kpis.Advance(now)
+	if now.After(nextPrune) {
+		nextPrune = now.Add(time.Minute)
+		if pruned := kpis.Prune(); pruned > 0 {
+			service.Debugf(3, "pruned %d idle kpi keys", pruned)
+		}
+	}
+	if statistics.Export <= 0 {
+		return
+	}
+	if nextExport.IsZero() {
+		nextExport = now.Add(time.Duration(statistics.Export) * time.Second)
+		return
+	}
+	if now.Before(nextExport) {
+		return
+	}
+	nextExport = now.Add(time.Duration(statistics.Export) * time.Second)
+	for key, windows := range kpis.Snapshot() {
+		Report(&cdr.Statistics{
+			Type:    cdr.StatisticsRecord,
+			Node:    config.Name,
+			Key:     key,
+			Time:    now,
+			Windows: windows,
+		})
+	}
+}
@@ -5,7 +5,17 @@ package main
 
 import (
 	"net"
+	"strings"
 	"time"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+	"spycraft/lib/channels"
+	"spycraft/lib/dialplan"
+	"spycraft/lib/events"
+	"spycraft/lib/recording"
+	"spycraft/lib/service"
+	"spycraft/lib/trunk"
 )
 
 type CallState int
@@ -19,25 +29,70 @@ type State struct {
 type LegEvent struct {
 	Method    []byte
 	Status    int
+	Sequence  uint32 // cseq number
+	Request   []byte // cseq method
 	Selected  *State
 	Timestamp time.Time
 	Endpoint  net.IP
 	Port      uint16
 }
 
+// Offer is a pending sdp offer waiting on an answer
+type Offer struct {
+	Selected *State
+	Sequence uint32
+	Method   string
+	Hold     bool
+	Early    bool // update in an early dialog
+	Delayed  bool // re-invite without sdp, the answer makes the offer
+	Dialog   *Dialog
+}
+
 type Leg struct {
-	Collated  string // will have CallID if neither end has collation
-	Agent     string
-	Endpoint  net.IP
-	Port      uint16
-	Incoming  bool
-	Pending   bool     // pending connection?
-	Connected bool     // Leg ever connected?
-	Final     int      // final status code of leg
-	States    [2]State // Local and remote state
-	Created   time.Time
-	Updated   time.Time
-	Finished  time.Time
+	ID            string // key in legs
+	Collated      string // will have CallID if neither end has collation
+	CallID        string
+	SessionID     string // rfc 7989 uuid of the inviter
+	SessionRemote string // rfc 7989 uuid of the invited
+	Agent         string
+	Codec         string // audio codec of the last answer, else offer
+	Caller        cdr.Party
+	Called        cdr.Party
+	Class         dialplan.Class
+	Redirects     []cdr.Redirect
+	Trunk         string
+	TrunkClass    trunk.Class
+	Realm         string        // uri host of the remote party
+	Channel       *channels.Key // channel seized until the leg ends
+	Endpoint      net.IP
+	Port          uint16
+	Incoming      bool
+	Pending       bool     // pending connection?
+	Connected     bool     // Leg ever connected?
+	Final         int      // final status code of leg
+	States        [2]State // Local and remote state
+	Offer         *Offer   // sdp offer in progress
+	Holds         int      // times put on hold
+	HoldTime      time.Duration
+	Held          time.Time     // when hold started
+	Transfer      *cdr.Transfer // refer made on or that created the leg
+	Dialogs       []*Dialog     // early and forked dialogs
+	Dialog        *Dialog       // dialog that answered
+	Interval      time.Duration // session interval requested
+	MinSE         time.Duration // largest min-se seen
+	Expired       string        // why leg was expired rather than ended
+	Disconnect    *cdr.Disconnect
+	Responded     time.Time  // last provisional response
+	Alerted       time.Time  // first ringing or session progress
+	EarlyMedia    time.Time  // early media started
+	Streams       [2]*Stream // media received by local and remote side
+	Recorder      *recording.Recorder
+	Recording     string // wav file of the call
+	Screened      bool   // recording rules checked
+	Created       time.Time
+	Answered      time.Time
+	Updated       time.Time
+	Finished      time.Time
 }
 
 const (
@@ -55,3 +110,224 @@ const (
 
 func (leg *Leg) Dummy(event *LegEvent) {
 }
+
+// Other returns the opposite state slot
+func (leg *Leg) Other(state *State) *State {
+	if state == &leg.States[0] {
+		return &leg.States[1]
+	}
+	return &leg.States[0]
+}
+
+func (leg *Leg) OnHold() bool {
+	return leg.States[0].Request == Hold || leg.States[1].Request == Hold
+}
+
+// Ringing marks the invited side as alerting
+func (leg *Leg) Ringing(event *LegEvent) {
+	if leg.Connected || event.Status < 180 {
+		return
+	}
+	ringing := leg.Other(event.Selected)
+	ringing.Request = Ring
+	ringing.Status = event.Status
+	ringing.Updated = event.Timestamp
+}
+
+func (leg *Leg) Answer(event *LegEvent) {
+	if leg.Connected {
+		return
+	}
+	leg.Connected = true
+	leg.Pending = false
+	leg.Final = event.Status
+	leg.Answered = event.Timestamp
+	for pos := range leg.States {
+		leg.States[pos].Request = Active
+		leg.States[pos].Status = event.Status
+		leg.States[pos].Updated = event.Timestamp
+	}
+}
+
+func (leg *Leg) Failure(event *LegEvent) {
+	leg.Final = event.Status
+	event.Selected.Request = Failed
+	event.Selected.Status = event.Status
+	event.Selected.Updated = event.Timestamp
+	leg.Finish(event.Timestamp)
+}
+
+// Media tracks an sdp offer so hold takes effect when answered
+func (leg *Leg) Media(event *LegEvent, held bool, dialog *Dialog) {
+	if !leg.Connected && dialog == nil {
+		return
+	}
+	leg.Offer = &Offer{
+		Selected: event.Selected,
+		Sequence: event.Sequence,
+		Method:   string(event.Request),
+		Hold:     held,
+		Dialog:   dialog,
+		Early:    !leg.Connected,
+	}
+	if !leg.Connected {
+		return
+	}
+	if event.Selected.Request != Hold {
+		event.Selected.Request = ReInvite
+	}
+	event.Selected.Updated = event.Timestamp
+}
+
+// Negotiate takes the codec of an offer until an answer settles it
+func (leg *Leg) Negotiate(sdp *byteshark.SDP, answer bool) {
+	if sdp == nil || (!answer && len(leg.Codec) > 0) {
+		return
+	}
+	if audio := sdp.Audio(); audio != nil {
+		if codec := audio.Codec(); len(codec) > 0 {
+			leg.Codec = codec
+		}
+	}
+}
+
+// Delay marks a re-invite without sdp as pending, its answer the offer
+func (leg *Leg) Delay(event *LegEvent, dialog *Dialog) {
+	leg.Offer = &Offer{
+		Selected: event.Selected,
+		Sequence: event.Sequence,
+		Method:   string(event.Request),
+		Dialog:   dialog,
+		Delayed:  true,
+	}
+	event.Selected.Request = ReInvite
+	event.Selected.Updated = event.Timestamp
+}
+
+// Accept applies or rejects the pending offer from the final response
+func (leg *Leg) Accept(event *LegEvent, sdp *byteshark.SDP) {
+	offer := leg.Offer
+	if offer == nil || offer.Sequence != event.Sequence || !strings.EqualFold(offer.Method, string(event.Request)) {
+		return
+	}
+	leg.Offer = nil
+	if event.Status >= 300 {
+		if offer.Selected.Request == ReInvite {
+			offer.Selected.Request = Active
+		}
+		return
+	}
+	if offer.Delayed {
+		if sdp != nil {
+			leg.SetHold(leg.Other(offer.Selected), sdp.Held(), event.Timestamp)
+		}
+		return
+	}
+	if offer.Early {
+		leg.EarlyUpdate(offer.Dialog, event)
+		return
+	}
+	leg.SetHold(offer.Selected, offer.Hold, event.Timestamp)
+}
+
+// SetHold changes hold state of a slot and accounts for hold time
+func (leg *Leg) SetHold(state *State, held bool, when time.Time) {
+	holding := leg.OnHold()
+	state.Updated = when
+	if held {
+		if state.Request != Hold {
+			leg.Holds++
+		}
+		state.Request = Hold
+	} else {
+		state.Request = Active
+	}
+
+	if !holding && leg.OnHold() {
+		leg.Held = when
+		service.Infof("hold leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+		leg.Emit(events.Held, when)
+	} else if holding && !leg.OnHold() {
+		leg.HoldTime += when.Sub(leg.Held)
+		leg.Held = time.Time{}
+		service.Infof("resume leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+		leg.Emit(events.Resumed, when)
+	}
+}
+
+func (leg *Leg) Finish(when time.Time) {
+	if !leg.Finished.IsZero() {
+		return
+	}
+	if leg.OnHold() {
+		leg.HoldTime += when.Sub(leg.Held)
+		leg.Held = time.Time{}
+	}
+	leg.Terminate(when)
+	leg.Finished = when
+	leg.Updated = when
+}
+
+func (leg *Leg) Record() *cdr.Record {
+	record := &cdr.Record{
+		Type:          cdr.LegRecord,
+		Node:          config.Name,
+		Collated:      leg.Collated,
+		CallID:        leg.CallID,
+		SessionID:     leg.SessionID,
+		SessionRemote: leg.SessionRemote,
+		Agent:         leg.Agent,
+		Codec:         leg.Codec,
+		Caller:        leg.Caller,
+		Called:        leg.Called,
+		Class:         string(leg.Class),
+		Redirects:     leg.Redirects,
+		Trunk:         leg.Trunk,
+		TrunkClass:    string(leg.TrunkClass),
+		Original:      leg.Original(),
+		Endpoint:      leg.Endpoint,
+		Port:          leg.Port,
+		Incoming:      leg.Incoming,
+		Connected:     leg.Connected,
+		Final:         leg.Final,
+		Created:       leg.Created,
+		Answered:      leg.Answered,
+		Finished:      leg.Finished,
+		Holds:         leg.Holds,
+		HoldTime:      service.Duration(leg.HoldTime),
+		Transfer:      leg.Transfer,
+		Dialogs:       len(leg.Dialogs),
+		Expired:       leg.Expired,
+		Disconnect:    leg.Disconnect,
+	}
+	if leg.Dialog != nil {
+		record.LocalTag = leg.Dialog.LocalTag
+		record.RemoteTag = leg.Dialog.RemoteTag
+		record.Session = service.Duration(leg.Dialog.Interval)
+		record.Refresher = leg.Side(leg.Dialog.Refresher)
+		record.Refreshes = leg.Dialog.Refreshes
+	}
+	record.MinSE = service.Duration(leg.MinSE)
+	for _, dialog := range leg.Dialogs {
+		record.Reliable += dialog.Reliable
+		record.Pracks += dialog.Pracks
+		record.Updates += dialog.Updates
+	}
+	if !leg.Alerted.IsZero() {
+		record.PostDial = leg.Alerted.Sub(leg.Created).Milliseconds()
+	}
+	if !leg.EarlyMedia.IsZero() {
+		ended := leg.Finished
+		if leg.Connected {
+			ended = leg.Answered
+		}
+		record.EarlyMedia = ended.Sub(leg.EarlyMedia).Milliseconds()
+	}
+	if leg.Connected {
+		record.Duration = service.Duration(leg.Finished.Sub(leg.Answered))
+	}
+	leg.Rate(Deck(leg.Trunk), record)
+	record.Media = leg.Quality()
+	record.Recording = leg.Recording
+	return record
+}
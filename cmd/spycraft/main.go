@@ -43,6 +43,7 @@ type Pipelines struct {
 	Capture int `ini:"capture"`
 	Scan    int `ini:"scan"`
 	Message int `ini:"message"`
+	Archive int `ini:"archive"`
 }
 
 var (
@@ -64,6 +65,7 @@ var (
 	pipelines = Pipelines{
 		Capture: 32,
 		Scan:    128,
+		Archive: 256,
 	}
 
 	packets  chan gopacket.Packet
@@ -133,6 +135,24 @@ func main() {
 		configs.MapTo(&config)
 		configs.Section("server").MapTo(&config)
 		configs.Section("pipelines").MapTo(&pipelines)
+		configs.Section("cdr").MapTo(&records)
+		configs.Section("limits").MapTo(&limits)
+		configs.Section("collation").MapTo(&collation)
+		configs.Section("kpi").MapTo(&statistics)
+		configs.Section("http").MapTo(&web)
+		configs.Section("channels").MapTo(&channeling)
+		configs.Section("store").MapTo(&storage)
+		configs.Section("media").MapTo(&media)
+		configs.Section("recording").MapTo(&recordings)
+		if err := OpenDialplan(configs); err != nil {
+			log.Fatal(err)
+		}
+		if err := OpenTrunks(configs); err != nil {
+			log.Fatal(err)
+		}
+		if err := OpenRating(configs); err != nil {
+			log.Fatal(err)
+		}
 	} else {
 		log.Fatal(err)
 	}
@@ -181,6 +201,23 @@ func main() {
 	legs = make(map[string]*Leg)
 	messages = make(chan *SIPMessage, pipelines.Message)
 	service.Logger(config.Verbose, logPrefix+"/spycraft.log")
+	OpenSinks()
+	defer CloseSinks()
+	if config.Capture {
+		OpenRecent()
+		OpenEvents()
+		OpenArchive()
+		defer CloseArchive()
+	}
+	OpenCollation()
+	OpenStatistics()
+	OpenChannels()
+	if err := OpenMedia(); err != nil {
+		log.Fatal(err)
+	}
+	if err := OpenRecording(); err != nil {
+		log.Fatal(err)
+	}
 	var wg sync.WaitGroup
 	if config.Capture {
 		timeout := time.Duration(config.Timeout) * time.Millisecond
@@ -204,6 +241,17 @@ func main() {
 		go Messages(&wg)
 		go Process(&wg)
 		go Capture(ctx, handle, &wg)
+		if media.Capture {
+			rtpHandle, err := pcap.OpenLive(config.Device, config.Snapshot, config.Promiscuous, timeout)
+			if err != nil {
+				service.Fail(-1, err)
+			}
+			wg.Add(1)
+			go MediaCapture(ctx, rtpHandle, &wg)
+		}
+		go Janitor(ctx)
+		go Retention(ctx)
+		Serve(ctx)
 		<-ctx.Done()
 	} else {
 		if len(config.Path) == 0 {
@@ -214,6 +262,9 @@ func main() {
 			service.Fail(-3, err)
 		}
 		config.Filter = byteshark.BuildBPFFilter(config.Host, config.Port)
+		if media.Capture {
+			config.Filter = "(" + config.Filter + ") or udp" // media follows sdp
+		}
 		handle, err := pcap.OpenOffline(config.Path)
 		if err != nil {
 			service.Fail(-1, err)
@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+	"spycraft/lib/rtp"
+	"spycraft/lib/service"
+)
+
+type Media struct {
+	Capture bool   `ini:"capture"` // capture rtp of sdp endpoints
+	Pairs   int    `ini:"pairs"`   // endpoints filtered before using ranges
+	Ports   string `ini:"ports"`   // port ranges to filter when over pairs
+}
+
+// MediaPacket is an rtp or rtcp datagram of a leg
+type MediaPacket struct {
+	Flow rtp.Flow
+	Data []byte
+}
+
+// Stream is the media seen in one direction of a leg
+type Stream struct {
+	SSRC     uint32
+	Payload  uint8
+	Quality  *rtp.Quality
+	Reported *cdr.Reported // by the receiving side
+	Sent     []Sent        // recent sender reports of the sending side
+	First    time.Time
+	Last     time.Time
+}
+
+// Sent is when a sender report was seen, to time round trips
+type Sent struct {
+	LSR uint32
+	At  time.Time
+}
+
+var (
+	media = Media{
+		Pairs: 64,
+	}
+
+	streams     = rtp.NewTable()
+	mediaRanges []rtp.Range
+	rtpPackets  = registry.CounterVec("spycraft_rtp_packets_total", "Media packets associated with legs", "kind")
+	rtpLost     = registry.CounterVec("spycraft_rtp_lost_total", "Media packets lost by side receiving", "side")
+	rtpStreams  = registry.CounterVec("spycraft_rtp_streams_total", "Media streams ended by estimated quality", "trunk", "quality")
+	rtpJitter   = registry.GaugeVec("spycraft_rtp_jitter_ms", "Jitter of the last stream ended by trunk", "trunk")
+	rtpMOS      = registry.GaugeVec("spycraft_rtp_mos", "Estimated mos of the last stream ended by trunk", "trunk")
+)
+
+func OpenMedia() error {
+	if !media.Capture {
+		return nil
+	}
+	ranges, err := rtp.ParseRanges(media.Ports)
+	if err != nil {
+		return err
+	}
+	mediaRanges = ranges
+	return nil
+}
+
+// Advertised binds the media endpoint of an sdp to the side that sent it
+func (leg *Leg) Advertised(sdp *byteshark.SDP, local bool) {
+	if !media.Capture || sdp == nil {
+		return
+	}
+	audio := sdp.Audio()
+	if audio == nil {
+		return
+	}
+	address := sdp.Address
+	if audio.Address != nil {
+		address = audio.Address
+	}
+	side := &leg.States[1]
+	if local {
+		side = &leg.States[0]
+	}
+	endpoint := rtp.Endpoint{IP: address, Port: audio.Port}
+	streams.Bind(leg.ID, endpoint, local)
+	service.Debugf(3, "%s media %v for %s", leg.Side(side), endpoint, leg.CallID)
+}
+
+// Forward passes udp of bound media endpoints on to their legs
+func Forward(udp *layers.UDP, sourceIP, targetIP net.IP, timestamp time.Time) {
+	if !media.Capture {
+		return
+	}
+	for _, flow := range streams.Match(sourceIP, uint16(udp.SrcPort), targetIP, uint16(udp.DstPort)) {
+		messages <- &SIPMessage{
+			Media:     &MediaPacket{Flow: flow, Data: udp.Payload},
+			Timestamp: timestamp,
+		}
+	}
+}
+
+// Streamed accounts for a media packet on the leg it is associated with
+func Streamed(message *SIPMessage) {
+	packet := message.Media
+	leg := legs[packet.Flow.Leg]
+	if leg == nil {
+		return
+	}
+	direction := 1 // sent to the remote side
+	if packet.Flow.Incoming {
+		direction = 0
+	}
+	if packet.Flow.RTCP {
+		compound, err := rtp.ParseRTCP(packet.Data)
+		if err != nil {
+			rtpPackets.With("invalid").Inc()
+			return
+		}
+		rtpPackets.With("rtcp").Inc()
+		leg.Reported(compound, 1-direction, message.Timestamp)
+		return
+	}
+	header, payload, err := rtp.Parse(packet.Data)
+	if err != nil {
+		rtpPackets.With("invalid").Inc()
+		return
+	}
+	rtpPackets.With("rtp").Inc()
+
+	stream := leg.Stream(direction, message.Timestamp)
+	if stream.SSRC != header.SSRC {
+		if stream.SSRC != 0 {
+			stream.Quality.Restart() // a new source continues the stream
+		}
+		stream.SSRC = header.SSRC
+		service.Debugf(3, "media ssrc %08x to %s side of %s", header.SSRC, leg.Side(&leg.States[direction]), leg.CallID)
+	}
+	stream.Payload = header.Payload
+	duplicate := stream.Quality.Add(header, message.Timestamp)
+	stream.Last = message.Timestamp
+	if !duplicate {
+		leg.Recorded(direction, header, payload, message.Timestamp) // mixing twice doubles it
+	}
+}
+
+// Stream returns the media received by a side of a leg
+func (leg *Leg) Stream(side int, when time.Time) *Stream {
+	stream := leg.Streams[side]
+	if stream == nil {
+		stream = &Stream{Quality: rtp.NewQuality(leg.Codec), First: when}
+		leg.Streams[side] = stream
+	}
+	return stream
+}
+
+// Reported takes what a side says by rtcp of the media it receives, and
+// times round trips from sender reports of the other side
+func (leg *Leg) Reported(compound *rtp.Compound, reporter int, when time.Time) {
+	if sender := compound.Sender; sender != nil {
+		sending := leg.Stream(1-reporter, when)
+		sending.Sent = append(sending.Sent, Sent{LSR: sender.Middle(), At: when})
+		if len(sending.Sent) > 8 {
+			sending.Sent = sending.Sent[1:]
+		}
+	}
+
+	stream := leg.Stream(reporter, when)
+	reported := stream.Reported
+	if reported == nil {
+		reported = &cdr.Reported{}
+		stream.Reported = reported
+	}
+	reported.Reports++
+	if len(compound.CNAME) > 0 {
+		reported.CNAME = compound.CNAME
+	}
+	for _, block := range compound.Blocks {
+		reported.Lost = block.Lost
+		reported.Loss = rounded(100 * float64(block.Fraction) / 256)
+		reported.Jitter = rounded(1000 * float64(block.Jitter) / float64(stream.Quality.Clock))
+		for _, sent := range stream.Sent {
+			if block.LSR == 0 || sent.LSR != block.LSR {
+				continue
+			}
+			delay := time.Duration(block.DLSR) * time.Second / 65536
+			if trip := when.Sub(sent.At) - delay; trip >= 0 {
+				reported.RoundTrip = rounded(float64(trip.Microseconds()) / 1000)
+			}
+		}
+	}
+	for _, metrics := range compound.VoIP {
+		if mos := metrics.MOS(); mos > 0 {
+			reported.MOS = mos
+		}
+		if rating := metrics.Rating(); rating > 0 {
+			reported.RFactor = rating
+		}
+		if reported.RoundTrip == 0 && metrics.RoundTrip > 0 {
+			reported.RoundTrip = float64(metrics.RoundTrip)
+		}
+	}
+	if compound.Bye {
+		reported.Bye = compound.Reason
+		if len(reported.Bye) == 0 {
+			reported.Bye = "bye"
+		}
+	}
+	service.Debugf(4, "rtcp from %s side of %s", leg.Side(&leg.States[reporter]), leg.CallID)
+}
+
+// Quality reports the media of each direction of a leg once it ends
+func (leg *Leg) Quality() []*cdr.Quality {
+	var reports []*cdr.Quality
+	for direction, stream := range leg.Streams {
+		if stream == nil {
+			continue
+		}
+		report := stream.Quality.Report(leg.Codec)
+		reports = append(reports, &cdr.Quality{
+			Direction:  leg.Side(&leg.States[direction]),
+			SSRC:       fmt.Sprintf("%08x", stream.SSRC),
+			Codec:      leg.Codec,
+			Packets:    report.Packets,
+			Lost:       report.Lost,
+			Loss:       rounded(report.Loss),
+			OutOfOrder: report.OutOfOrder,
+			Duplicates: report.Duplicates,
+			Jitter:     rounded(report.Jitter),
+			MaxDelta:   rounded(report.MaxDelta),
+			RFactor:    rounded(report.RFactor),
+			MOS:        rounded(report.MOS),
+			Reported:   stream.Reported,
+		})
+	}
+	return reports
+}
+
+// Scored exposes the media quality of an ended leg as metrics
+func Scored(record *cdr.Record) {
+	for _, quality := range record.Media {
+		rtpLost.With(quality.Direction).Add(quality.Lost)
+		rtpStreams.With(record.Trunk, rated(quality.MOS)).Inc()
+		rtpJitter.Set(quality.Jitter, record.Trunk)
+		rtpMOS.Set(quality.MOS, record.Trunk)
+	}
+}
+
+// rated bands a mos the way voice quality is usually described
+func rated(mos float64) string {
+	switch {
+	case mos >= 4.0:
+		return "good"
+	case mos >= 3.6:
+		return "fair"
+	}
+	return "poor"
+}
+
+func rounded(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// MediaCapture reads a second handle whose filter follows the endpoints
+// bound from sdp, passing packets into the same pipeline as sip
+func MediaCapture(ctx context.Context, handle *pcap.Handle, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer handle.Close()
+	service.Noticef("starting media capture from %s", config.Device)
+	version := streams.Version() - 1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if current := streams.Version(); current != version {
+			filter := streams.Filter(media.Pairs, mediaRanges, config.Port)
+			if err := handle.SetBPFFilter(filter); err != nil {
+				service.Error(err)
+			} else {
+				service.Debugf(3, "media filter \"%s\"", filter)
+			}
+			version = current
+		}
+
+		data, info, err := handle.ReadPacketData()
+		if errors.Is(err, pcap.NextErrorTimeoutExpired) {
+			continue
+		}
+		if err != nil {
+			service.Error(err)
+			return
+		}
+		packet := gopacket.NewPacket(data, handle.LinkType(), gopacket.Default)
+		packet.Metadata().CaptureInfo = info
+		select {
+		case packets <- packet:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
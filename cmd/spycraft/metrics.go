@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/gopacket/pcap"
+
+	"spycraft/lib/metrics"
+)
+
+var (
+	registry = metrics.NewRegistry()
+
+	sipRequests  = registry.CounterVec("spycraft_sip_requests_total", "SIP requests seen by method", "method")
+	sipResponses = registry.CounterVec("spycraft_sip_responses_total", "SIP responses seen by status code", "code")
+	parseErrors  = registry.Counter("spycraft_parse_errors_total", "SIP messages that could not be parsed")
+	trunkCalls   = registry.GaugeVec("spycraft_trunk_calls", "Connected calls by trunk", "trunk")
+
+	published   atomic.Pointer[LegStats]
+	captureLock sync.Mutex
+	capturing   *pcap.Handle
+)
+
+var sipMethods = []string{"INVITE", "ACK", "BYE", "CANCEL", "OPTIONS", "REGISTER", "PRACK", "SUBSCRIBE", "NOTIFY", "PUBLISH", "INFO", "REFER", "MESSAGE", "UPDATE"}
+
+func init() {
+	registry.GaugeFunc("spycraft_legs_active", "Call legs being tracked", func() float64 {
+		return float64(snapshot().Active)
+	})
+	registry.GaugeFunc("spycraft_legs_peak", "Most call legs tracked at once", func() float64 {
+		return float64(snapshot().Peak)
+	})
+	registry.CounterFunc("spycraft_legs_created_total", "Call legs started", func() float64 {
+		return float64(snapshot().Created)
+	})
+	registry.CounterFunc("spycraft_legs_completed_total", "Call legs completed", func() float64 {
+		return float64(snapshot().Completed)
+	})
+	registry.CounterFunc("spycraft_legs_expired_total", "Call legs expired without ending", func() float64 {
+		return float64(snapshot().Expired)
+	})
+	registry.CounterFunc("spycraft_legs_rejected_total", "Call legs not tracked over the leg limit", func() float64 {
+		return float64(snapshot().Rejected)
+	})
+	registry.GaugeFunc("spycraft_packets_queued", "Depth of the packets pipeline", func() float64 {
+		return float64(len(packets))
+	})
+	registry.GaugeFunc("spycraft_messages_queued", "Depth of the messages pipeline", func() float64 {
+		return float64(len(messages))
+	})
+	registry.CounterFunc("spycraft_packets_received_total", "Packets received by the capture handle", func() float64 {
+		return float64(captureStats().PacketsReceived)
+	})
+	registry.CounterFunc("spycraft_packets_dropped_total", "Packets dropped by the capture handle", func() float64 {
+		return float64(captureStats().PacketsDropped)
+	})
+	registry.CounterFunc("spycraft_packets_ifdropped_total", "Packets dropped by the interface", func() float64 {
+		return float64(captureStats().PacketsIfDropped)
+	})
+}
+
+// Publish copies leg stats and trunk calls from the messages goroutine
+func Publish() {
+	stats := legStats
+	published.Store(&stats)
+	calls := make(map[string]int)
+	for _, leg := range legs {
+		if !leg.Connected {
+			continue
+		}
+		if len(leg.Trunk) == 0 {
+			calls["none"]++ // not classified into a trunk
+		} else {
+			calls[leg.Trunk]++
+		}
+	}
+	trunkCalls.Replace(calls)
+}
+
+// Counted tallies a request by method or a response by status code
+func Counted(method, status []byte) {
+	if len(method) == 0 {
+		code, err := strconv.Atoi(string(status))
+		if err != nil || code < 100 || code > 699 {
+			sipResponses.With("other").Inc() // no series per junk status
+			return
+		}
+		sipResponses.With(strconv.Itoa(code)).Inc()
+		return
+	}
+	name := strings.ToUpper(string(method))
+	for _, known := range sipMethods {
+		if name == known {
+			sipRequests.With(name).Inc()
+			return
+		}
+	}
+	sipRequests.With("OTHER").Inc()
+}
+
+func snapshot() *LegStats {
+	if stats := published.Load(); stats != nil {
+		return stats
+	}
+	return &LegStats{}
+}
+
+func captureStats() *pcap.Stats {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+	if capturing != nil {
+		if stats, err := capturing.Stats(); err == nil {
+			return stats
+		}
+	}
+	return &pcap.Stats{}
+}
+
+func setCapture(handle *pcap.Handle) {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+	capturing = handle
+}
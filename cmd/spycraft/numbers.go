@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+	"spycraft/lib/dialplan"
+)
+
+var (
+	numbering = &dialplan.Plan{}
+	plans     = make(map[string]*dialplan.Plan)
+)
+
+// OpenDialplan loads [dialplan] and its [dialplan.name] trunk overrides
+func OpenDialplan(configs *ini.File) error {
+	section := configs.Section("dialplan")
+	if err := section.MapTo(numbering); err != nil {
+		return err
+	}
+	if err := numbering.Compile(); err != nil {
+		return err
+	}
+	for _, child := range section.ChildSections() {
+		plan := &dialplan.Plan{}
+		if err := child.MapTo(plan); err != nil {
+			return err
+		}
+		if err := plan.Compile(); err != nil {
+			return err
+		}
+		plans[strings.TrimPrefix(child.Name(), "dialplan.")] = plan
+	}
+	return nil
+}
+
+// Plan finds the numbering rules for a trunk or the default
+func Plan(name string) *dialplan.Plan {
+	if plan := plans[strings.ToLower(name)]; plan != nil {
+		return plan
+	}
+	return numbering
+}
+
+// Normalize fills e164 numbers of leg parties and classifies the call
+func (leg *Leg) Normalize(plan *dialplan.Plan) {
+	normalize(plan, &leg.Caller)
+	leg.Class = normalize(plan, &leg.Called)
+}
+
+func normalize(plan *dialplan.Plan, party *cdr.Party) dialplan.Class {
+	if len(party.Number) == 0 {
+		return dialplan.Unknown
+	}
+	number := party.Number
+	context := byteshark.ParseParam([]byte(party.URI), "phone-context")
+	if pos := bytes.IndexByte(context, '@'); pos > -1 {
+		context = context[:pos]
+	}
+	number = dialplan.WithContext(number, string(context))
+	result := plan.Normalize(number)
+	party.E164 = result.E164
+	return result.Class
+}
@@ -30,7 +30,7 @@ func Process(wg *sync.WaitGroup) {
 		if config.Host.To4() != nil {
 			ipLayer := packet.Layer(layers.LayerTypeIPv4)
 			if ipLayer == nil {
-				return
+				continue
 			}
 			ip, _ := ipLayer.(*layers.IPv4)
 			sourceIP = ip.SrcIP
@@ -38,16 +38,13 @@ func Process(wg *sync.WaitGroup) {
 		} else if config.Host.To16() != nil {
 			ipLayer := packet.Layer(layers.LayerTypeIPv6)
 			if ipLayer == nil {
-				return
+				continue
 			}
 			ip, _ := ipLayer.(*layers.IPv6)
 			sourceIP = ip.SrcIP
 			targetIP = ip.DstIP
 		}
 		service.Debugf(4, "PACKET %v = %v", sourceIP, targetIP)
-		if !sourceIP.Equal(config.Host) && !targetIP.Equal(config.Host) {
-			return
-		}
 
 		var sourcePort uint16
 		var targetPort uint16
@@ -59,12 +56,12 @@ func Process(wg *sync.WaitGroup) {
 			remotePort := targetPort
 			remoteIP := targetIP
 			incoming := false
-			if sourcePort == config.Port && sourceIP.Equal(config.Host) {
-				incoming = true
-			} else if targetPort == config.Port && targetIP.Equal(config.Host) {
+			if targetPort == config.Port && targetIP.Equal(config.Host) {
+				incoming = true // sent to us by the remote endpoint
 				remotePort = sourcePort
 				remoteIP = sourceIP
-			} else {
+			} else if sourcePort != config.Port || !sourceIP.Equal(config.Host) {
+				Forward(udp, sourceIP, targetIP, packet.Metadata().Timestamp)
 				continue
 			}
 			service.Debugf(3, "UDP %v/%v to %v/%v", sourceIP, sourcePort, targetIP, targetPort)
@@ -93,15 +90,17 @@ func Scan(handle *pcap.Handle) {
 
 func Capture(ctx context.Context, handle *pcap.Handle, wg *sync.WaitGroup) {
 	service.Noticef("starting capture from %s for %v/%v", config.Device, config.Host, config.Port)
+	setCapture(handle)
 	defer handle.Close()
+	defer setCapture(nil)
 	source := gopacket.NewPacketSource(handle, handle.LinkType())
-	packets := source.Packets()
+	input := source.Packets()
 	defer wg.Done()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case packet, ok := <-packets:
+		case packet, ok := <-input:
 			if !ok {
 				err := fmt.Errorf("packet source closed")
 				service.Error(err)
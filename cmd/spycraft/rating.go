@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+
+	"spycraft/lib/cdr"
+	"spycraft/lib/rating"
+)
+
+type Rating struct {
+	Deck      string `ini:"deck"` // csv rate deck path
+	Currency  string `ini:"currency"`
+	Precision int    `ini:"precision"`
+}
+
+var (
+	tariff *rating.Deck
+	decks  = make(map[string]*rating.Deck)
+)
+
+// OpenRating loads the [rating] deck and [rating.name] trunk or customer decks
+func OpenRating(configs *ini.File) error {
+	section := configs.Section("rating")
+	deck, err := loadDeck("", section)
+	if err != nil {
+		return err
+	}
+	tariff = deck
+	for _, child := range section.ChildSections() {
+		name := strings.TrimPrefix(child.Name(), "rating.")
+		deck, err := loadDeck(name, child)
+		if err != nil {
+			return err
+		}
+		if deck != nil {
+			decks[strings.ToLower(name)] = deck
+		}
+	}
+	return nil
+}
+
+// Deck finds the rate deck for a trunk or customer or the default
+func Deck(name string) *rating.Deck {
+	if deck := decks[strings.ToLower(name)]; deck != nil {
+		return deck
+	}
+	return tariff
+}
+
+// Rate prices a connected leg we sent by the called number, so inbound
+// legs and the arriving half of a b2bua call are not billed
+func (leg *Leg) Rate(deck *rating.Deck, record *cdr.Record) {
+	if deck == nil || !leg.Connected || leg.Incoming {
+		return
+	}
+	number := leg.Called.E164
+	if len(number) == 0 {
+		number = leg.Called.Number
+	}
+	rate := deck.Match(number)
+	if rate == nil {
+		return
+	}
+	cost, billed := deck.Cost(rate, time.Duration(record.Duration))
+	record.Rating = &cdr.Rating{
+		Deck:     deck.Name,
+		Prefix:   rate.Prefix,
+		Rate:     rate.PerMinute,
+		Billed:   billed,
+		Cost:     cost,
+		Currency: deck.Currency,
+	}
+}
+
+func loadDeck(name string, section *ini.Section) (*rating.Deck, error) {
+	options := Rating{Precision: 4}
+	if err := section.MapTo(&options); err != nil {
+		return nil, err
+	}
+	if len(options.Deck) == 0 || options.Deck == "none" {
+		return nil, nil
+	}
+	deck, err := rating.LoadDeck(name, options.Deck)
+	if err != nil {
+		return nil, err
+	}
+	deck.Precision = options.Precision
+	deck.Currency = options.Currency
+	return deck, nil
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"spycraft/lib/recording"
+	"spycraft/lib/rtp"
+	"spycraft/lib/service"
+)
+
+type Recording struct {
+	Path    string   `ini:"path"`              // directory of wav files, none to disable
+	Stereo  bool     `ini:"stereo"`            // each side a channel, else mixed
+	Trunks  []string `ini:"trunks" delim:","`  // trunks to record, * for all
+	Numbers []string `ini:"numbers" delim:","` // caller or called number patterns
+}
+
+var (
+	recordings = Recording{
+		Path:   "none",
+		Stereo: true,
+	}
+
+	recordNumbers []*regexp.Regexp
+	recordCalls   = make(map[string]string) // collation id to leg recording it
+)
+
+func OpenRecording() error {
+	if !recordingEnabled() {
+		return nil
+	}
+	if !media.Capture {
+		service.Warnf("recording requires media capture")
+		return nil
+	}
+	for _, number := range recordings.Numbers {
+		pattern, err := regexp.Compile(number)
+		if err != nil {
+			return fmt.Errorf("recording number %s: %w", number, err)
+		}
+		recordNumbers = append(recordNumbers, pattern)
+	}
+	return os.MkdirAll(recordings.Path, 0750)
+}
+
+// Recorded writes g.711 media received by a side into the call recording
+func (leg *Leg) Recorded(side int, header rtp.Header, payload []byte, when time.Time) {
+	if leg.Recorder == nil {
+		if leg.Screened {
+			return
+		}
+		leg.Screened = true
+		if !leg.Recordable() {
+			return
+		}
+		leg.StartRecording(when)
+		if leg.Recorder == nil {
+			return
+		}
+	}
+	if err := leg.Recorder.Write(side, header, payload, when); err != nil {
+		service.Error(err)
+		leg.StopRecording()
+	}
+}
+
+// Recordable tests the recording rules against the trunk and numbers
+func (leg *Leg) Recordable() bool {
+	if !recordingEnabled() || !media.Capture {
+		return false
+	}
+	if slices.Contains(recordings.Trunks, "*") || (len(leg.Trunk) > 0 && slices.Contains(recordings.Trunks, leg.Trunk)) {
+		return true
+	}
+	for _, pattern := range recordNumbers {
+		for _, number := range []string{leg.Caller.Number, leg.Caller.E164, leg.Called.Number, leg.Called.E164} {
+			if len(number) > 0 && pattern.MatchString(number) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StartRecording creates the wav file of a call named by collation id,
+// once per call when a b2bua shows it as several legs
+func (leg *Leg) StartRecording(when time.Time) {
+	call := leg.Collated
+	if len(call) == 0 {
+		call = leg.CallID
+	}
+	if owner, found := recordCalls[call]; found && owner != leg.ID {
+		return
+	}
+	name := safeName(call)
+	path := filepath.Join(recordings.Path, name+".wav")
+	for count := 1; ; count++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(recordings.Path, fmt.Sprintf("%s-%d.wav", name, count))
+	}
+	recorder, err := recording.Create(path, recordings.Stereo, when)
+	if err != nil {
+		service.Error(err)
+		return
+	}
+	leg.Recorder = recorder
+	leg.Recording = path
+	recordCalls[call] = leg.ID
+	service.Infof("recording %s to %s", call, path)
+}
+
+// StopRecording finishes the wav file of a leg
+func (leg *Leg) StopRecording() {
+	if leg.Recorder == nil {
+		return
+	}
+	for call, owner := range recordCalls {
+		if owner == leg.ID {
+			delete(recordCalls, call)
+		}
+	}
+	if err := leg.Recorder.Close(); err != nil {
+		service.Error(err)
+	}
+	service.Debugf(2, "recorded %v of %s", leg.Recorder.Duration(), leg.CallID)
+	leg.Recorder = nil
+}
+
+// CloseRecordings finishes recordings of legs still active at end of input
+func CloseRecordings() {
+	for _, leg := range legs {
+		leg.StopRecording()
+	}
+}
+
+func recordingEnabled() bool {
+	return len(recordings.Path) > 0 && recordings.Path != "none"
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+
+	"spycraft/lib/archive"
+	"spycraft/lib/recording"
+	"spycraft/lib/rtp"
+	"spycraft/lib/trunk"
+)
+
+// TestRecordingPcap plays rtp from a pcap through the packet and media
+// pipeline, with the trunk that selects recording classified late
+func TestRecordingPcap(t *testing.T) {
+	config.Host, config.Port = net.ParseIP("10.0.0.1"), 5060
+	media.Capture = true
+	recordings = Recording{Path: t.TempDir(), Stereo: false, Trunks: []string{"carrier"}}
+	trunks = &trunk.Table{}
+	if err := trunks.Add(&trunk.Trunk{Name: "carrier", Agents: []string{"carrier-gw"}}); err != nil {
+		t.Fatal(err)
+	}
+	leg := &Leg{ID: "10.0.0.9/5060/call1", CallID: "call1", Collated: "call1", Endpoint: net.ParseIP("10.0.0.9"), Port: 5060, Codec: "PCMU"}
+	legs = map[string]*Leg{leg.ID: leg}
+	streams.Bind(leg.ID, rtp.Endpoint{IP: config.Host, Port: 10000}, true)
+	defer streams.Release(leg.ID)
+
+	// ten mu-law frames to the local side, the sixth sent twice
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var sent []*archive.Message
+	for _, index := range []int{0, 1, 2, 3, 4, 5, 5, 6, 7, 8, 9} {
+		packet := make([]byte, 12, 12+160)
+		packet[0] = 0x80
+		binary.BigEndian.PutUint16(packet[2:], uint16(index))
+		binary.BigEndian.PutUint32(packet[4:], 5000+uint32(index)*160)
+		binary.BigEndian.PutUint32(packet[8:], 0x1234)
+		sent = append(sent, &archive.Message{
+			Time:   start.Add(time.Duration(index) * 20 * time.Millisecond),
+			Source: "10.0.0.9:20000",
+			Target: "10.0.0.1:10000",
+			Data:   append(packet, bytes.Repeat([]byte{0x80}, 160)...),
+		})
+	}
+	var capture bytes.Buffer
+	if err := archive.WritePcap(&capture, sent); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := pcapgo.NewReader(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packets = make(chan gopacket.Packet, len(sent)+1)
+	messages = make(chan *SIPMessage, len(sent)+1)
+	for packet := range gopacket.NewPacketSource(reader, reader.LinkType()).Packets() {
+		packets <- packet
+	}
+	packets <- nil
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go Process(&wg)
+	count := 0
+	for message := range messages {
+		if message == nil {
+			break
+		}
+		if count == 3 && !leg.Classify("carrier-gw") {
+			t.Fatal("trunk not classified")
+		}
+		Streamed(message)
+		count++
+	}
+	wg.Wait()
+	if count != len(sent) {
+		t.Fatalf("Expected %d packets, but got %d", len(sent), count)
+	}
+	if leg.Recorder == nil {
+		t.Fatal("Expected recording once the trunk is classified")
+	}
+	leg.StopRecording()
+
+	data, err := os.ReadFile(leg.Recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const frame = 160 * 2 // mixed 16 bit samples
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 7*frame {
+		t.Fatalf("Expected 7 frames from the late trunk on, but got %d bytes", size)
+	}
+	for index := range 7 {
+		sample := int16(binary.LittleEndian.Uint16(data[44+index*frame+80*2:]))
+		if sample != recording.DecodeUlaw([]byte{0x80}, nil)[0] {
+			t.Fatalf("Expected frame %d mixed once, but got %d", index, sample)
+		}
+	}
+	if report := leg.Streams[0].Quality.Report(leg.Codec); report.Packets != 11 || report.Duplicates != 1 {
+		t.Fatalf("Unexpected stream %+v", report)
+	}
+}
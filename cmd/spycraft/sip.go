@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+	"spycraft/lib/events"
 	"spycraft/lib/service"
 )
 
@@ -21,6 +23,7 @@ type SIPMessage struct {
 	RemotePort uint16
 	Incoming   bool
 	Timestamp  time.Time
+	Media      *MediaPacket // rtp or rtcp rather than sip
 }
 
 func Messages(wg *sync.WaitGroup) {
@@ -28,17 +31,38 @@ func Messages(wg *sync.WaitGroup) {
 	var parts_store [4][]byte
 	var fields_store [4][]byte
 	var headers_store [64][]byte
+	var diversion_store [8][]byte
+	var history_store [8][]byte
+	var reason_store [4][]byte
 	var err error
 	for {
-		message := <-messages
+		var message *SIPMessage
+		select {
+		case message = <-messages:
+		case query := <-queries:
+			query()
+			continue
+		}
 		if message == nil {
+			CloseRecordings()
 			return
 		}
+		Sweep(message.Timestamp)
+		Collect(message.Timestamp)
+		Peaks(message.Timestamp)
+		if message.Media != nil {
+			Streamed(message)
+			continue
+		}
+		if len(message.Data) == 0 {
+			continue // janitor tick
+		}
 
 		parts := parts_store[:0]
 		count := byteshark.SplitSections(message.Data, []byte("\r\n\r\n"), &parts)
 		if count < 1 {
 			service.Error("Unable to split sections")
+			parseErrors.Inc()
 			continue
 		}
 		service.Debugf(4, "Split into %d sections", count)
@@ -47,6 +71,7 @@ func Messages(wg *sync.WaitGroup) {
 		count = byteshark.SplitSections(parts[0], []byte("\r\n"), &headers)
 		if count < 2 {
 			service.Error("No sip headers")
+			parseErrors.Inc()
 			continue
 		}
 		service.Debugf(5, "Split header into %d lines", count)
@@ -55,6 +80,7 @@ func Messages(wg *sync.WaitGroup) {
 		byteshark.SplitSections(headers[0], []byte(" "), &fields)
 		if len(fields) != 3 {
 			service.Error("Not a sip packet")
+			parseErrors.Inc()
 			continue
 		}
 
@@ -75,11 +101,17 @@ func Messages(wg *sync.WaitGroup) {
 			service.Debugf(3, "Request: %s %s %s", method, uri, version)
 		}
 
-		var key, value, callid, collateid, agent []byte
+		var key, value, callid, collateid, agent, cseq, content []byte
+		var from, to, referto, referredby, replaces, pkg, expires, minse, rseq, rack []byte
+		var asserted, rpid, preferred, privacy, original, session []byte
+		var warning int
+		diversions := diversion_store[:0]
+		histories := history_store[:0]
+		reasons := reason_store[:0]
 		for i := 1; i < len(headers); i++ {
 			key, value = byteshark.SplitKeypair(headers[i], ':')
 			service.Debugf(6, "%s: %s", key, value)
-			if byteshark.MatchKeyword(key, []byte("call-id")) {
+			if byteshark.MatchHeader(key, "call-id", "i") {
 				callid = value
 				continue
 			}
@@ -87,12 +119,104 @@ func Messages(wg *sync.WaitGroup) {
 				collateid = value
 				continue
 			}
+			if byteshark.MatchHeader(key, "cseq", "") {
+				cseq = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "content-type", "c") {
+				content = byteshark.ParseContentType(value)
+				continue
+			}
+			if byteshark.MatchHeader(key, "from", "f") {
+				from = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "to", "t") {
+				to = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "refer-to", "r") {
+				referto = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "referred-by", "b") {
+				referredby = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "replaces", "") {
+				replaces = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "session-expires", "x") {
+				expires = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "rseq", "") {
+				rseq = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "rack", "") {
+				rack = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "min-se", "") {
+				minse = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "p-asserted-identity", "") {
+				asserted = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "remote-party-id", "") {
+				rpid = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "p-preferred-identity", "") {
+				preferred = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "privacy", "") {
+				privacy = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "diversion", "") {
+				byteshark.SplitList(value, &diversions)
+				continue
+			}
+			if byteshark.MatchHeader(key, "history-info", "") {
+				byteshark.SplitList(value, &histories)
+				continue
+			}
+			if byteshark.MatchHeader(key, "reason", "") {
+				byteshark.SplitList(value, &reasons)
+				continue
+			}
+			if byteshark.MatchHeader(key, "warning", "") {
+				if warning == 0 {
+					warning = byteshark.ParseDelta(value) // leading warn-code
+				}
+				continue
+			}
+			if byteshark.MatchHeader(key, "session-id", "") {
+				session = value
+				continue
+			}
+			if OriginalHeader(key) {
+				original = value
+				continue
+			}
+			if byteshark.MatchHeader(key, "event", "o") {
+				pkg = value
+				continue
+			}
 			// collect from incoming packets...
 			if message.Incoming && byteshark.MatchKeyword(key, []byte("user-agent")) {
 				agent = value
 			}
 		}
+		Counted(method, status)
 		if len(callid) == 0 {
+			parseErrors.Inc()
 			continue
 		}
 		event := &LegEvent{
@@ -106,6 +230,7 @@ func Messages(wg *sync.WaitGroup) {
 			event.Status, err = strconv.Atoi(string(status))
 			if err != nil {
 				service.Error(err)
+				parseErrors.Inc()
 				continue
 			}
 		}
@@ -113,28 +238,73 @@ func Messages(wg *sync.WaitGroup) {
 		if event.Status >= 800 {
 			continue
 		}
+		event.Sequence, event.Request = byteshark.ParseCSeq(cseq)
+
+		var sdp *byteshark.SDP
+		if len(parts) > 1 && byteshark.MatchKeyword(content, []byte("application/sdp")) {
+			sdp = byteshark.ParseSDP(parts[1])
+		}
+
+		// local tag is the one from our host side of the message
+		local := byteshark.ParseParam(from, "tag")
+		remote := byteshark.ParseParam(to, "tag")
+		if message.Incoming == (len(method) > 0) {
+			local, remote = remote, local
+		}
+		dialogid := DialogID(callid, local, remote)
+		dialog := dialogs[dialogid]
 
 		legid := fmt.Sprintf("%v/%v/%s", message.RemoteIP, message.RemotePort, callid)
 		leg := legs[legid]
 		if leg == nil && event.Status == 0 {
-			// we should make sure this is not a re-invite...
-			if byteshark.MatchKeyword(method, []byte("invite")) {
+			// a to tag means a re-invite for a leg we do not have
+			if byteshark.MatchKeyword(method, []byte("invite")) && len(byteshark.ParseParam(to, "tag")) == 0 {
+				if !Admit() {
+					service.Debugf(1, "leg limit reached, ignoring %s", legid)
+					continue
+				}
 				leg = &Leg{
+					ID:       legid,
+					CallID:   string(callid),
 					Incoming: incoming,
+					Pending:  true,
 					Created:  message.Timestamp,
 					Updated:  message.Timestamp,
 					Endpoint: message.RemoteIP,
 					Port:     message.RemotePort,
 				}
+				leg.Requested(expires, minse)
+				if incoming {
+					leg.Realm = string(byteshark.ParseURIHost(byteshark.ParseURI(from)))
+					leg.Classify(string(agent))
+				} else {
+					leg.Realm = string(byteshark.ParseURIHost(uri))
+					leg.Classify("")
+				}
 
 				// if we are the inviter, can set collation id immediately
-				if !incoming {
-					if len(collateid) > 0 {
-						leg.Collated = string(collateid)
-					} else {
-						leg.Collated = string(callid)
+				leg.Identify(session, true)
+				leg.Parties(uri, from, to, asserted, rpid, preferred, privacy, diversions, histories)
+				leg.Normalize(Plan(leg.Trunk))
+				leg.Negotiate(sdp, false)
+				leg.Advertised(sdp, !message.Incoming)
+				evidence := leg.Evidence(legid, original, sdp)
+				if leg.Referred(uri, replaces, referredby) {
+					service.Infof("transferred leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+				} else if !incoming {
+					if len(leg.SessionID) == 0 && len(collateid) == 0 {
+						leg.Correlate(evidence)
+					}
+					if len(leg.Collated) == 0 {
+						leg.Collated = leg.CollationKey(collateid)
 					}
 					service.Infof("outgoing leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+				} else if len(leg.SessionID) > 0 {
+					leg.Collated = leg.SessionID // session id is end to end
+					service.Infof("incoming leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+				}
+				if incoming && collation.Enabled {
+					correlator.Arrived(evidence) // may be continued by a b2bua
 				}
 
 				if incoming {
@@ -148,28 +318,35 @@ func Messages(wg *sync.WaitGroup) {
 				leg.States[0].Updated = message.Timestamp
 				leg.States[1].Updated = message.Timestamp
 				legs[legid] = leg
+				leg.Seize(message.Timestamp)
+				leg.Archived(message)
+				leg.Emit(events.Created, message.Timestamp)
 				continue
 			}
 		}
 
 		// we strip events if we didn't see initial invite
 		if leg == nil {
+			Closing(message, callid)
 			continue
 		}
 
 		// collate if we are responding and nothing set
+		leg.Identify(session, len(method) > 0 && message.Incoming == leg.Incoming)
 		if incoming && event.Status >= 180 && len(leg.Collated) == 0 {
-			if len(collateid) > 0 {
-				leg.Collated = string(collateid)
-			} else {
-				leg.Collated = string(callid)
-			}
+			leg.Collated = leg.CollationKey(collateid)
 			service.Infof("incoming leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
 		}
+		leg.Archived(message)
 
 		leg.Updated = message.Timestamp
-		if message.Incoming && len(leg.Agent) == 0 {
+		leg.Negotiate(sdp, len(method) == 0)
+		leg.Advertised(sdp, !message.Incoming)
+		if message.Incoming && len(leg.Agent) == 0 && len(agent) > 0 {
 			leg.Agent = string(agent) // fill from remote endpoint
+			if len(leg.Trunk) == 0 && leg.Classify(leg.Agent) {
+				leg.Normalize(Plan(leg.Trunk)) // parties of the trunk dialplan
+			}
 		}
 		if incoming {
 			if len(leg.Agent) == 0 {
@@ -185,40 +362,104 @@ func Messages(wg *sync.WaitGroup) {
 			leg.Dummy(event)
 			continue
 		}
-		if event.Status >= 600 {
-			leg.Dummy(event)
-			continue
-		}
-		if event.Status >= 500 {
-			leg.Dummy(event)
-			continue
-		}
-		if event.Status >= 400 {
-			leg.Dummy(event)
-			continue
-		}
 		if event.Status >= 300 {
-			leg.Dummy(event)
+			inviting := byteshark.MatchKeyword(event.Request, []byte("invite"))
+			if byteshark.MatchKeyword(event.Request, []byte("refer")) {
+				leg.Refused(event)
+				continue
+			}
+			if event.Status == 422 {
+				leg.Requested(nil, minse) // session interval too small
+			}
+			leg.Accept(event, sdp)
+			if inviting && event.Selected.Request == ReInvite {
+				event.Selected.Request = Active
+			}
+			if inviting && !leg.Connected {
+				leg.Terminate(event.Timestamp)
+				leg.Failure(event)
+				leg.Disconnected(leg.Other(event.Selected), cdr.ByResponse, event.Status, warning, reasons)
+				service.Infof("failed leg %v/%v on %s with %d", leg.Endpoint, leg.Port, leg.Collated, event.Status)
+				End(legid, leg)
+			}
 			continue
 		}
 		if event.Status >= 200 {
-			leg.Dummy(event)
+			inviting := byteshark.MatchKeyword(event.Request, []byte("invite"))
+			if inviting && len(remote) > 0 && len(local) > 0 {
+				dialog = leg.Confirm(dialogid, event, local, remote)
+			}
+			if leg.Stray(dialog) {
+				continue // losing fork, ignore till its bye
+			}
+			if inviting && !leg.Connected {
+				leg.Answer(event)
+				service.Infof("answered leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
+				leg.Emit(events.Answered, event.Timestamp)
+			}
+			leg.Accept(event, sdp)
+			if inviting && event.Selected.Request == ReInvite {
+				event.Selected.Request = Active
+			}
+			if inviting || byteshark.MatchKeyword(event.Request, []byte("update")) {
+				if dialog == nil {
+					dialog = leg.Dialog
+				}
+				leg.Refresh(dialog, event, expires)
+			}
 			continue
 		}
 		if event.Status >= 100 {
-			leg.Dummy(event)
+			inviting := byteshark.MatchKeyword(event.Request, []byte("invite"))
+			if inviting && !leg.Connected {
+				leg.Responded = message.Timestamp
+			}
+			if inviting && !leg.Connected && len(remote) > 0 && len(local) > 0 {
+				dialog = leg.Early(dialogid, event, local, remote)
+			}
+			if inviting {
+				leg.Ringing(event)
+				leg.Provisional(dialog, event, rseq, sdp != nil)
+			}
 			continue
 		}
-		if byteshark.MatchKeyword(method, []byte("invite")) {
-			leg.Dummy(event)
+		if byteshark.MatchKeyword(method, []byte("invite")) || byteshark.MatchKeyword(method, []byte("update")) {
+			if leg.Stray(dialog) {
+				continue
+			}
+			leg.Requested(expires, minse)
+			if sdp != nil {
+				leg.Media(event, sdp.Held(), dialog)
+			} else if leg.Connected && byteshark.MatchKeyword(method, []byte("invite")) {
+				leg.Delay(event, dialog)
+			}
+			continue
+		}
+		if byteshark.MatchKeyword(method, []byte("bye")) && leg.Stray(dialog) {
+			dialog.State = Terminated
+			dialog.Updated = message.Timestamp
 			continue
 		}
 		if byteshark.MatchKeyword(method, []byte("bye")) || byteshark.MatchKeyword(method, []byte("cancel")) {
 			if len(leg.Collated) > 0 {
 				service.Infof("ending leg %v/%v on %s", leg.Endpoint, leg.Port, leg.Collated)
 			}
-			leg.Finished = message.Timestamp
-			leg.Dummy(event)
+			event.Selected.Request = Bye
+			event.Selected.Updated = message.Timestamp
+			if !leg.Connected && leg.Final == 0 {
+				leg.Final = 487
+			}
+			if byteshark.MatchKeyword(method, []byte("cancel")) {
+				leg.Disconnected(event.Selected, cdr.ByCancel, 0, 0, reasons)
+			} else {
+				leg.Disconnected(event.Selected, cdr.ByBye, 0, 0, reasons)
+			}
+			leg.Finish(message.Timestamp)
+			End(legid, leg)
+			continue
+		}
+		if byteshark.MatchKeyword(method, []byte("prack")) {
+			leg.Prack(dialog, event, rack)
 			continue
 		}
 		if byteshark.MatchKeyword(method, []byte("ack")) {
@@ -226,7 +467,13 @@ func Messages(wg *sync.WaitGroup) {
 			continue
 		}
 		if byteshark.MatchKeyword(method, []byte("refer")) {
-			leg.Dummy(event)
+			leg.Refer(event, referto, referredby, from, to)
+			continue
+		}
+		if byteshark.MatchKeyword(method, []byte("notify")) && byteshark.MatchKeyword(pkg, []byte("refer")) {
+			if len(parts) > 1 && byteshark.MatchKeyword(content, []byte("message/sipfrag")) {
+				leg.Notified(event, byteshark.ParseSipfrag(parts[1]))
+			}
 			continue
 		}
 		leg.Dummy(event)
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"time"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/service"
+)
+
+// Requested remembers a session interval asked for in a request
+func (leg *Leg) Requested(expires, minse []byte) {
+	leg.Interval = time.Duration(byteshark.ParseDelta(expires)) * time.Second
+	if value := time.Duration(byteshark.ParseDelta(minse)) * time.Second; value > leg.MinSE {
+		leg.MinSE = value
+	}
+}
+
+// Refresh applies the rfc 4028 session timer from a 2xx to invite or update
+func (leg *Leg) Refresh(dialog *Dialog, event *LegEvent, expires []byte) {
+	requested := leg.Interval
+	leg.Interval = 0
+	if dialog == nil {
+		return
+	}
+
+	interval := time.Duration(byteshark.ParseDelta(expires)) * time.Second
+	refresher := byteshark.ParseParam(expires, "refresher")
+	if interval == 0 && requested > 0 {
+		interval = requested // uas without timer support, uac refreshes
+		refresher = nil
+	}
+	if interval == 0 {
+		dialog.Interval = 0
+		dialog.Refresher = nil
+		dialog.Deadline = time.Time{}
+		return
+	}
+
+	dialog.Refresher = event.Selected
+	if string(refresher) == "uas" {
+		dialog.Refresher = leg.Other(event.Selected)
+	}
+	if !dialog.Refreshed.IsZero() {
+		dialog.Refreshes++
+	}
+	dialog.Interval = interval
+	dialog.Refreshed = event.Timestamp
+	dialog.Deadline = event.Timestamp.Add(interval)
+	service.Debugf(3, "session refresh for %s by %s until %v", dialog.CallID, leg.Side(dialog.Refresher), dialog.Deadline)
+}
+
+// Side names a state slot as local or remote
+func (leg *Leg) Side(state *State) string {
+	switch state {
+	case &leg.States[0]:
+		return "local"
+	case &leg.States[1]:
+		return "remote"
+	}
+	return ""
+}
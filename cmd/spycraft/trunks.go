@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	"spycraft/lib/service"
+	"spycraft/lib/trunk"
+)
+
+var trunks = &trunk.Table{}
+
+// OpenTrunks loads [trunks.name] sections, matched in the order given
+func OpenTrunks(configs *ini.File) error {
+	for _, child := range configs.Section("trunks").ChildSections() {
+		peer := &trunk.Trunk{}
+		if err := child.MapTo(peer); err != nil {
+			return err
+		}
+		peer.Name = strings.TrimPrefix(child.Name(), "trunks.")
+		if err := trunks.Add(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Classify tags a leg with the trunk its remote endpoint belongs to
+func (leg *Leg) Classify(agent string) bool {
+	found := trunks.Match(leg.Endpoint, leg.Port, leg.Realm, agent)
+	if found == nil {
+		return false
+	}
+	seized := leg.Channel != nil
+	if seized {
+		leg.Release(leg.Updated) // move channel to the trunk
+	}
+	leg.Trunk = found.Name
+	leg.TrunkClass = found.Class
+	leg.Screened = false // recording rules may select the trunk
+	if seized {
+		leg.Seize(leg.Updated)
+	}
+	service.Debugf(2, "leg %v/%v on trunk %s", leg.Endpoint, leg.Port, leg.Trunk)
+	return true
+}
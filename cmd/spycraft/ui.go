@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed ui
+var assets embed.FS
+
+// routeUI serves the call search and ladder web interface
+func routeUI() {
+	files, err := fs.Sub(assets, "ui")
+	if err != nil {
+		panic(err)
+	}
+	router.Handle("GET /", http.FileServer(http.FS(files)))
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+"use strict";
+
+const svgns = "http://www.w3.org/2000/svg";
+
+function $(selector) {
+  return document.querySelector(selector);
+}
+
+async function fetchJSON(path) {
+  const response = await fetch(path);
+  if (!response.ok) {
+    return null;
+  }
+  return response.json();
+}
+
+function party(p) {
+  if (!p) {
+    return "";
+  }
+  return p.name ? `${p.name} <${p.number}>` : p.number;
+}
+
+function seconds(duration) {
+  return duration ? `${duration}s` : "";
+}
+
+function when(time) {
+  if (!time || time.startsWith("0001")) {
+    return "";
+  }
+  return new Date(time).toLocaleString();
+}
+
+function row(body, cells, onclick) {
+  const tr = document.createElement("tr");
+  for (const cell of cells) {
+    const td = document.createElement("td");
+    td.textContent = cell ?? "";
+    tr.appendChild(td);
+  }
+  tr.onclick = onclick;
+  body.appendChild(tr);
+}
+
+function direction(incoming) {
+  return incoming ? "in" : "out";
+}
+
+async function search(event) {
+  if (event) {
+    event.preventDefault();
+  }
+  const params = new URLSearchParams();
+  for (const input of $("#filters").elements) {
+    if (!input.name || !input.value) {
+      continue;
+    }
+    const value = input.type === "datetime-local" ? new Date(input.value).toISOString() : input.value;
+    params.set(input.name, value);
+  }
+
+  const active = $("#active tbody");
+  active.replaceChildren();
+  const legs = await fetchJSON("api/legs" + (params.has("trunk") ? `?trunk=${encodeURIComponent(params.get("trunk"))}` : ""));
+  for (const leg of legs || []) {
+    row(active, [when(leg.created), leg.state, leg.trunk, direction(leg.incoming), party(leg.caller),
+      party(leg.called), leg.codec, seconds(leg.duration)], () => show(leg.collated || leg.callid));
+  }
+
+  const completed = $("#completed tbody");
+  completed.replaceChildren();
+  const records = await fetchJSON("api/cdrs?" + params.toString());
+  for (const record of records || []) {
+    const cause = record.disconnect ? `${record.disconnect.q850} ${record.disconnect.text || ""}` : "";
+    row(completed, [when(record.created), record.final, record.trunk, direction(record.incoming), party(record.caller),
+      party(record.called), seconds(record.duration), cause], () => show(record.collated || record.callid));
+  }
+}
+
+async function show(collated) {
+  $("#search").hidden = true;
+  $("#call").hidden = false;
+  $("#collated").textContent = collated;
+  $("#message").textContent = "";
+
+  const legs = $("#legs tbody");
+  legs.replaceChildren();
+  const call = await fetchJSON(`api/calls/${encodeURIComponent(collated)}`);
+  if (call) {
+    for (const leg of call.active || []) {
+      row(legs, [leg.callid, leg.state, leg.trunk, direction(leg.incoming), party(leg.caller), party(leg.called),
+        "", seconds(leg.duration)]);
+    }
+    for (const record of call.completed || []) {
+      row(legs, [record.callid, "ended", record.trunk, direction(record.incoming), party(record.caller),
+        party(record.called), record.final, seconds(record.duration)]);
+    }
+  }
+  ladder(await fetchJSON(`api/calls/${encodeURIComponent(collated)}/messages`) || []);
+}
+
+function ladder(messages) {
+  const view = $("#ladder");
+  view.replaceChildren();
+  if (messages.length === 0) {
+    view.textContent = "No stored messages for this call.";
+    return;
+  }
+
+  const nodes = [];
+  for (const message of messages) {
+    for (const address of [message.source, message.target]) {
+      if (!nodes.includes(address)) {
+        nodes.push(address);
+      }
+    }
+  }
+
+  const spacing = 220, top = 40, step = 28, left = 110;
+  const width = left * 2 + spacing * (nodes.length - 1);
+  const height = top + step * (messages.length + 1);
+  const svg = document.createElementNS(svgns, "svg");
+  svg.setAttribute("width", width);
+  svg.setAttribute("height", height);
+
+  const x = (address) => left + spacing * nodes.indexOf(address);
+  nodes.forEach((address) => {
+    svg.appendChild(shape("text", { x: x(address), y: 20, "text-anchor": "middle", class: "node" }, address));
+    svg.appendChild(shape("line", { x1: x(address), y1: top - 10, x2: x(address), y2: height, stroke: "#aaa" }));
+  });
+
+  const start = new Date(messages[0].time).getTime();
+  messages.forEach((message, pos) => {
+    const y = top + step * (pos + 1);
+    const from = x(message.source), to = x(message.target);
+    const response = message.summary.startsWith("SIP/");
+    const label = response ? message.summary.replace(/^SIP\/2\.0\s+/, "") : message.summary.split(" ")[0];
+    const group = shape("g", { class: `arrow ${response ? "response" : "request"}` });
+    const head = from < to ? -8 : 8;
+    group.appendChild(shape("line", { x1: from, y1: y, x2: to, y2: y, "stroke-width": 1.5 }));
+    group.appendChild(shape("polyline", { points: `${to + head},${y - 4} ${to},${y} ${to + head},${y + 4}`, fill: "none", stroke: "currentColor" }));
+    group.appendChild(shape("text", { x: (from + to) / 2, y: y - 4, "text-anchor": "middle" }, label));
+    group.appendChild(shape("text", { x: 4, y: y + 4 }, `+${((new Date(message.time).getTime() - start) / 1000).toFixed(3)}`));
+    group.onclick = () => {
+      $("#message").textContent = `${when(message.time)} ${message.source} -> ${message.target}\n\n${message.text}`;
+    };
+    svg.appendChild(group);
+  });
+  view.appendChild(svg);
+}
+
+function shape(name, attributes, text) {
+  const element = document.createElementNS(svgns, name);
+  for (const [key, value] of Object.entries(attributes)) {
+    element.setAttribute(key, value);
+  }
+  if (text !== undefined) {
+    element.textContent = text;
+  }
+  return element;
+}
+
+async function start() {
+  const status = await fetchJSON("api/status");
+  if (status) {
+    $("#node").textContent = `${status.node} on ${status.device}`;
+  }
+  $("#filters").onsubmit = search;
+  $("#back").onclick = () => {
+    $("#call").hidden = true;
+    $("#search").hidden = false;
+  };
+  search();
+}
+
+start();
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"time"
+
+	"spycraft/lib/byteshark"
+	"spycraft/lib/cdr"
+	"spycraft/lib/events"
+	"spycraft/lib/service"
+)
+
+// Referral is a transfer waiting for the transferee to invite the target
+type Referral struct {
+	Collated string
+	CallID   string
+	Transfer *cdr.Transfer
+	Created  time.Time
+}
+
+// how long a refer can wait for the new invite, 64*T1
+const referralExpires = 32 * time.Second
+
+var referrals = make(map[string]*Referral)
+
+// Refer starts tracking a transfer requested on this leg
+func (leg *Leg) Refer(event *LegEvent, referto, referredby, from, to []byte) {
+	target, headers := byteshark.SplitURIHeaders(byteshark.ParseURI(referto))
+	if len(target) == 0 {
+		return
+	}
+
+	transferor := byteshark.ParseURI(referredby)
+	if len(transferor) == 0 {
+		transferor = byteshark.ParseURI(from)
+	}
+	leg.Transfer = &cdr.Transfer{
+		Kind:       cdr.BlindTransfer,
+		Transferor: string(transferor),
+		Transferee: string(byteshark.ParseURI(to)),
+		Target:     string(target),
+	}
+
+	if len(leg.Collated) == 0 {
+		leg.Collated = leg.CallID
+	}
+	referral := &Referral{
+		Collated: leg.Collated,
+		CallID:   leg.CallID,
+		Transfer: leg.Transfer,
+		Created:  event.Timestamp,
+	}
+
+	replaces := parseReplaces(uriHeader(headers, "replaces"))
+	if len(replaces) > 0 {
+		leg.Transfer.Kind = cdr.AttendedTransfer
+		leg.Transfer.Replaces = replaces
+		referrals["replaces/"+replaces] = referral
+		for _, other := range legs {
+			if other.CallID == replaces {
+				other.Collated = leg.Collated // consultation joins the call
+			}
+		}
+	} else if user := byteshark.ParseURIUser(target); len(user) > 0 {
+		referrals["target/"+string(user)] = referral
+	}
+
+	event.Selected.Request = Xfer
+	event.Selected.Updated = event.Timestamp
+	service.Infof("%s transfer leg %v/%v on %s to %s", leg.Transfer.Kind, leg.Endpoint, leg.Port, leg.Collated, target)
+	leg.Emit(events.Transferred, event.Timestamp)
+}
+
+// Refused is a refer request that was rejected
+func (leg *Leg) Refused(event *LegEvent) {
+	if leg.Transfer == nil || leg.Transfer.Status != 0 {
+		return
+	}
+	leg.Transfer.Status = event.Status
+	leg.Emit(events.Transferred, event.Timestamp)
+	if event.Selected.Request == Xfer {
+		event.Selected.Request = Active
+		event.Selected.Updated = event.Timestamp
+	}
+}
+
+// Notified applies sipfrag progress of a transfer on this leg
+func (leg *Leg) Notified(event *LegEvent, status int) {
+	if leg.Transfer == nil || leg.Transfer.Status != 0 || status < 100 {
+		return
+	}
+	if status < 200 {
+		leg.Transfer.Progress = status
+		service.Debugf(2, "transfer leg %v/%v on %s progress %d", leg.Endpoint, leg.Port, leg.Collated, status)
+		leg.Emit(events.Transferred, event.Timestamp)
+		return
+	}
+	leg.Transfer.Status = status
+	service.Infof("transfer leg %v/%v on %s completed with %d", leg.Endpoint, leg.Port, leg.Collated, status)
+	leg.Emit(events.Transferred, event.Timestamp)
+	if status < 300 {
+		return
+	}
+	for pos := range leg.States {
+		if leg.States[pos].Request == Xfer {
+			leg.States[pos].Request = Active
+			leg.States[pos].Updated = event.Timestamp
+		}
+	}
+}
+
+// Referred links a new leg to the transfer that created it
+func (leg *Leg) Referred(uri, replaces, referredby []byte) bool {
+	var referral *Referral
+	if callid := parseReplaces(string(replaces)); len(callid) > 0 {
+		referral = referrals["replaces/"+callid]
+	} else if len(referredby) > 0 {
+		if user := byteshark.ParseURIUser(uri); len(user) > 0 {
+			referral = referrals["target/"+string(user)]
+		}
+	}
+	if referral == nil {
+		return false
+	}
+
+	transfer := *referral.Transfer
+	transfer.Original = referral.CallID
+	leg.Transfer = &transfer
+	leg.Collated = referral.Collated
+	return true
+}
+
+// ExpireReferrals removes transfers that never produced a new leg
+func ExpireReferrals(now time.Time) {
+	for key, referral := range referrals {
+		if now.Sub(referral.Created) > referralExpires {
+			delete(referrals, key)
+		}
+	}
+}
+
+func uriHeader(headers []byte, name string) string {
+	for _, header := range bytes.Split(headers, []byte("&")) {
+		key, value := byteshark.SplitKeypair(header, '=')
+		if !strings.EqualFold(string(key), name) {
+			continue
+		}
+		if text, err := url.QueryUnescape(string(value)); err == nil {
+			return text
+		}
+		return string(value)
+	}
+	return ""
+}
+
+// replaces header or refer-to parameter leads with the replaced call id
+func parseReplaces(value string) string {
+	callid := []byte(value)
+	if pos := bytes.IndexByte(callid, ';'); pos > -1 {
+		callid = callid[:pos]
+	}
+	return string(bytes.TrimSpace(callid))
+}
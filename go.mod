@@ -12,5 +12,6 @@ require (
 
 require (
 	github.com/alexflint/go-scalar v1.2.0 // indirect
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
 )
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package archive
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Message is a sip message as seen on the wire
+type Message struct {
+	Time     time.Time `json:"time"`
+	CallID   string    `json:"callid"`
+	Incoming bool      `json:"incoming"` // sent to our host
+	Source   string    `json:"source"`   // address:port
+	Target   string    `json:"target"`
+	Data     []byte    `json:"-"`
+}
+
+// Store keeps sip messages of calls by call id and collation id
+type Store interface {
+	Add(message *Message) error
+	Link(collated, callid string)
+	Call(key string) ([]*Message, error) // by collation or call id
+	Close() error
+}
+
+// Memory keeps messages of the most recent calls
+type Memory struct {
+	lock     sync.Mutex
+	calls    int // calls kept
+	messages int // messages kept per call
+	callids  map[string][]*Message
+	order    []string            // call ids oldest first
+	collated map[string][]string // collation id to call ids
+	owners   map[string]string   // call id to collation id
+}
+
+func NewMemory(calls, messages int) *Memory {
+	return &Memory{
+		calls:    calls,
+		messages: messages,
+		callids:  make(map[string][]*Message),
+		collated: make(map[string][]string),
+		owners:   make(map[string]string),
+	}
+}
+
+func (memory *Memory) Add(message *Message) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	stored, found := memory.callids[message.CallID]
+	if !found {
+		memory.order = append(memory.order, message.CallID)
+		for memory.calls > 0 && len(memory.order) > memory.calls {
+			memory.evict(memory.order[0])
+			memory.order = memory.order[1:]
+		}
+	}
+	if memory.messages > 0 && len(stored) >= memory.messages {
+		return nil // keep the setup of long calls
+	}
+	memory.callids[message.CallID] = append(stored, message)
+	return nil
+}
+
+// Link indexes a call id under a collation id, moving it if it changed
+func (memory *Memory) Link(collated, callid string) {
+	if len(collated) == 0 {
+		return
+	}
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if _, found := memory.callids[callid]; !found {
+		return
+	}
+	owner := memory.owners[callid]
+	if owner == collated {
+		return
+	}
+	if len(owner) > 0 {
+		memory.unlink(owner, callid)
+	}
+	memory.owners[callid] = collated
+	memory.collated[collated] = append(memory.collated[collated], callid)
+}
+
+// Call returns messages of a collation or call id in time order
+func (memory *Memory) Call(key string) ([]*Message, error) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	callids := memory.collated[key]
+	if len(callids) == 0 {
+		callids = []string{key}
+	}
+	var found []*Message
+	for _, callid := range callids {
+		found = append(found, memory.callids[callid]...)
+	}
+	Sort(found)
+	return found, nil
+}
+
+func (memory *Memory) Close() error {
+	return nil
+}
+
+func (memory *Memory) evict(callid string) {
+	delete(memory.callids, callid)
+	if owner := memory.owners[callid]; len(owner) > 0 {
+		memory.unlink(owner, callid)
+		delete(memory.owners, callid)
+	}
+}
+
+func (memory *Memory) unlink(collated, callid string) {
+	callids := memory.collated[collated]
+	for pos, linked := range callids {
+		if linked == callid {
+			callids = append(callids[:pos], callids[pos+1:]...)
+			break
+		}
+	}
+	if len(callids) == 0 {
+		delete(memory.collated, collated)
+	} else {
+		memory.collated[collated] = callids
+	}
+}
+
+// Sort orders messages by time, keeping capture order of equal times
+func Sort(messages []*Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Time.Before(messages[j].Time)
+	})
+}
+
+// Summary is the request or status line of a message
+func (message *Message) Summary() string {
+	line := message.Data
+	if end := bytes.IndexByte(line, '\n'); end > -1 {
+		line = line[:end]
+	}
+	return string(bytes.TrimRight(line, "\r"))
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package archive
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	memory := NewMemory(2, 3)
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	add := func(callid string, offset int, line string) {
+		memory.Add(&Message{
+			Time:   start.Add(time.Duration(offset) * time.Millisecond),
+			CallID: callid,
+			Data:   []byte(line + "\r\nCall-ID: " + callid + "\r\n\r\n"),
+		})
+	}
+
+	add("a", 0, "INVITE sip:100@example.com SIP/2.0")
+	add("b", 5, "INVITE sip:200@example.com SIP/2.0")
+	add("a", 10, "SIP/2.0 180 Ringing")
+	add("b", 15, "SIP/2.0 200 OK")
+	for pos := 0; pos < 3; pos++ {
+		add("a", 20+pos, fmt.Sprintf("INFO sip:100@example.com SIP/2.0 %d", pos))
+	}
+	memory.Link("call", "a")
+	memory.Link("call", "b")
+
+	messages, _ := memory.Call("call")
+	if len(messages) != 5 {
+		t.Fatalf("Expected 5 messages, but got %d", len(messages))
+	}
+	if messages[1].Summary() != "INVITE sip:200@example.com SIP/2.0" || messages[4].Summary() != "INFO sip:100@example.com SIP/2.0 0" {
+		t.Errorf("Unexpected order %s ... %s", messages[1].Summary(), messages[4].Summary())
+	}
+
+	// a third call evicts the oldest and its collation link
+	add("c", 30, "INVITE sip:300@example.com SIP/2.0")
+	if messages, _ := memory.Call("a"); len(messages) != 0 {
+		t.Errorf("Expected call a evicted, but got %d messages", len(messages))
+	}
+	if messages, _ := memory.Call("call"); len(messages) != 2 {
+		t.Errorf("Expected 2 messages of b, but got %d", len(messages))
+	}
+
+	// relinking moves a call id to a new collation
+	memory.Link("other", "b")
+	if messages, _ := memory.Call("other"); len(messages) != 2 {
+		t.Errorf("Expected 2 messages, but got %d", len(messages))
+	}
+	if messages, _ := memory.Call("call"); len(messages) != 0 {
+		t.Errorf("Expected unlinked collation, but got %d", len(messages))
+	}
+}
@@ -0,0 +1,481 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Disk keeps messages as json lines of one file per call id, in the hourly
+// directory a call started in, with collation links appended to a links
+// file of each hour, files being kept open for writes. Retention removes
+// whole hours by the latest write of their calls
+type Disk struct {
+	Age  time.Duration // hours kept, 0 for no limit
+	Size int64         // bytes kept, 0 for no limit
+
+	lock     sync.Mutex
+	path     string
+	used     int64
+	hours    map[string][]string  // hour to call ids stored in it
+	calls    map[string][]string  // call id to hours, oldest first
+	collated map[string][]string  // collation id to call ids
+	owners   map[string]string    // call id to collation id
+	latest   map[string]time.Time // hour to latest message written in it
+	files    map[string]*os.File  // open for append by path
+}
+
+type entry struct {
+	Time     time.Time `json:"time"`
+	CallID   string    `json:"callid"`
+	Incoming bool      `json:"incoming"`
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	Data     []byte    `json:"data"`
+}
+
+type link struct {
+	Collated string `json:"collated"`
+	CallID   string `json:"callid"`
+}
+
+const (
+	hourLayout = "20060102/15"
+	linksFile  = "links.jsonl"
+	callSuffix = ".jsonl"
+	openFiles  = 128 // most files kept open for append
+	nameLimit  = 200 // longer names are hashed, under name_max of 255
+	hashMarker = "~" // escaped in call ids, so marks a hashed name
+)
+
+// OpenDisk indexes an existing store or creates a new one
+func OpenDisk(path string) (*Disk, error) {
+	if err := os.MkdirAll(path, 0750); err != nil {
+		return nil, err
+	}
+	disk := &Disk{
+		path:     path,
+		hours:    make(map[string][]string),
+		calls:    make(map[string][]string),
+		collated: make(map[string][]string),
+		owners:   make(map[string]string),
+		latest:   make(map[string]time.Time),
+		files:    make(map[string]*os.File),
+	}
+	days, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		if !day.IsDir() {
+			continue
+		}
+		hours, err := os.ReadDir(filepath.Join(path, day.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, hour := range hours {
+			key := day.Name() + "/" + hour.Name()
+			if _, err := time.Parse(hourLayout, key); err != nil || !hour.IsDir() {
+				continue
+			}
+			if err := disk.index(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return disk, nil
+}
+
+func (disk *Disk) Add(message *Message) error {
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+	hours := disk.calls[message.CallID]
+	hour := ""
+	if len(hours) > 0 {
+		hour = hours[len(hours)-1]
+	} else {
+		hour = message.Time.UTC().Format(hourLayout)
+		if err := os.MkdirAll(filepath.Join(disk.path, hour), 0750); err != nil {
+			return err
+		}
+		disk.calls[message.CallID] = []string{hour}
+		disk.hours[hour] = append(disk.hours[hour], message.CallID)
+	}
+	if message.Time.After(disk.latest[hour]) {
+		disk.latest[hour] = message.Time
+	}
+
+	data, err := json.Marshal(&entry{
+		Time:     message.Time,
+		CallID:   message.CallID,
+		Incoming: message.Incoming,
+		Source:   message.Source,
+		Target:   message.Target,
+		Data:     message.Data,
+	})
+	if err != nil {
+		return err
+	}
+	return disk.append(filepath.Join(disk.path, hour, fileName(message.CallID)+callSuffix), data)
+}
+
+func (disk *Disk) Link(collated, callid string) {
+	if len(collated) == 0 {
+		return
+	}
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+	hours := disk.calls[callid]
+	if len(hours) == 0 || disk.owners[callid] == collated {
+		return
+	}
+	data, err := json.Marshal(&link{Collated: collated, CallID: callid})
+	if err == nil {
+		err = disk.append(filepath.Join(disk.path, hours[len(hours)-1], linksFile), data)
+	}
+	if err != nil {
+		return
+	}
+	disk.relink(collated, callid)
+}
+
+func (disk *Disk) Call(key string) ([]*Message, error) {
+	disk.lock.Lock()
+	callids := append([]string(nil), disk.collated[key]...)
+	if len(callids) == 0 {
+		callids = []string{key}
+	}
+	var paths []string
+	for _, callid := range callids {
+		for _, hour := range disk.calls[callid] {
+			paths = append(paths, filepath.Join(disk.path, hour, fileName(callid)+callSuffix))
+		}
+	}
+	disk.lock.Unlock()
+
+	var found []*Message
+	for _, path := range paths {
+		messages, err := readCall(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		found = append(found, messages...)
+	}
+	Sort(found)
+	return found, nil
+}
+
+// Calls lists stored call ids with their collation id
+func (disk *Disk) Calls() map[string]string {
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+	calls := make(map[string]string, len(disk.calls))
+	for callid := range disk.calls {
+		calls[callid] = disk.owners[callid]
+	}
+	return calls
+}
+
+// Used is bytes stored
+func (disk *Disk) Used() int64 {
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+	return disk.used
+}
+
+// Prune removes hours last written to before the age limit, then oldest
+// hours till under the size limit, other than hours written to this hour
+// by calls still running
+func (disk *Disk) Prune(now time.Time) (int, error) {
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+	hours := make([]string, 0, len(disk.hours))
+	for hour := range disk.hours {
+		hours = append(hours, hour)
+	}
+	sort.Strings(hours)
+	current := now.UTC().Format(hourLayout)
+	removed := 0
+	for _, hour := range hours {
+		latest := disk.latest[hour]
+		if hour >= current || latest.UTC().Format(hourLayout) >= current {
+			continue
+		}
+		expired := disk.Age > 0 && now.Sub(latest) > disk.Age
+		oversize := disk.Size > 0 && disk.used > disk.Size
+		if !expired && !oversize {
+			continue
+		}
+		if err := disk.remove(hour); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (disk *Disk) Close() error {
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+	var err error
+	for path, file := range disk.files {
+		if closed := file.Close(); closed != nil && err == nil {
+			err = closed
+		}
+		delete(disk.files, path)
+	}
+	return err
+}
+
+func (disk *Disk) index(hour string) error {
+	dir := filepath.Join(disk.path, hour)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	disk.hours[hour] = nil
+	disk.latest[hour], _ = time.Parse(hourLayout, hour)
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			return err
+		}
+		disk.used += info.Size()
+		name := file.Name()
+		if name == linksFile || !strings.HasSuffix(name, callSuffix) {
+			continue
+		}
+		last, err := readLast(filepath.Join(dir, name))
+		if err == nil && last.Time.After(disk.latest[hour]) {
+			disk.latest[hour] = last.Time
+		}
+		callid := callID(strings.TrimSuffix(name, callSuffix))
+		if strings.Contains(name, hashMarker) {
+			if err != nil {
+				continue // call id is only kept inside
+			}
+			callid = last.CallID
+		}
+		disk.hours[hour] = append(disk.hours[hour], callid)
+		disk.calls[callid] = append(disk.calls[callid], hour)
+	}
+
+	file, err := os.Open(filepath.Join(dir, linksFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var found link
+		if json.Unmarshal(scanner.Bytes(), &found) == nil && len(found.Collated) > 0 {
+			disk.relink(found.Collated, found.CallID)
+		}
+	}
+	return scanner.Err()
+}
+
+func (disk *Disk) remove(hour string) error {
+	dir := filepath.Join(disk.path, hour)
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	prefix := dir + string(filepath.Separator)
+	for path, file := range disk.files {
+		if strings.HasPrefix(path, prefix) {
+			file.Close()
+			delete(disk.files, path)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	os.Remove(filepath.Dir(dir)) // day, if now empty
+	disk.used -= size
+	for _, callid := range disk.hours[hour] {
+		hours := disk.calls[callid]
+		for pos, stored := range hours {
+			if stored == hour {
+				hours = append(hours[:pos], hours[pos+1:]...)
+				break
+			}
+		}
+		if len(hours) > 0 {
+			disk.calls[callid] = hours
+			continue
+		}
+		delete(disk.calls, callid)
+		if owner := disk.owners[callid]; len(owner) > 0 {
+			disk.unlink(owner, callid)
+			delete(disk.owners, callid)
+		}
+	}
+	delete(disk.hours, hour)
+	delete(disk.latest, hour)
+	return nil
+}
+
+func (disk *Disk) relink(collated, callid string) {
+	if owner := disk.owners[callid]; len(owner) > 0 {
+		disk.unlink(owner, callid)
+	}
+	disk.owners[callid] = collated
+	disk.collated[collated] = append(disk.collated[collated], callid)
+}
+
+func (disk *Disk) unlink(collated, callid string) {
+	callids := disk.collated[collated]
+	for pos, linked := range callids {
+		if linked == callid {
+			callids = append(callids[:pos], callids[pos+1:]...)
+			break
+		}
+	}
+	if len(callids) == 0 {
+		delete(disk.collated, collated)
+	} else {
+		disk.collated[collated] = callids
+	}
+}
+
+// append writes a line to a file kept open, closing any one other file
+// when too many are open
+func (disk *Disk) append(path string, data []byte) error {
+	file := disk.files[path]
+	if file == nil {
+		if len(disk.files) >= openFiles {
+			for other, open := range disk.files {
+				open.Close()
+				delete(disk.files, other)
+				break
+			}
+		}
+		var err error
+		file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0640)
+		if err != nil {
+			return err
+		}
+		disk.files[path] = file
+	}
+	count, err := file.Write(append(data, '\n'))
+	disk.used += int64(count)
+	if err != nil {
+		file.Close()
+		delete(disk.files, path)
+	}
+	return err
+}
+
+func readCall(path string) ([]*Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var messages []*Message
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var stored entry
+		if err := json.Unmarshal(scanner.Bytes(), &stored); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		messages = append(messages, &Message{
+			Time:     stored.Time,
+			CallID:   stored.CallID,
+			Incoming: stored.Incoming,
+			Source:   stored.Source,
+			Target:   stored.Target,
+			Data:     stored.Data,
+		})
+	}
+	return messages, scanner.Err()
+}
+
+// readLast reads the last message of a call file, from its tail as a sip
+// message fits well within it
+func readLast(path string) (*entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-64*1024, 0)
+	data := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if pos := bytes.LastIndexByte(data, '\n'); pos > -1 {
+		data = data[pos+1:]
+	}
+	var last entry
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &last, nil
+}
+
+// fileName escapes a call id to a safe file name, hashing names too long
+// for the file system
+func fileName(callid string) string {
+	var name strings.Builder
+	for _, b := range []byte(callid) {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '-', b == '_', b == '.', b == '@':
+			name.WriteByte(b)
+		default:
+			fmt.Fprintf(&name, "%%%02x", b)
+		}
+	}
+	escaped := name.String()
+	if len(escaped) > 0 && escaped[0] == '.' {
+		escaped = "%2e" + escaped[1:]
+	}
+	if len(escaped) > nameLimit {
+		hash := sha256.Sum256([]byte(callid))
+		escaped = escaped[:nameLimit-33] + hashMarker + hex.EncodeToString(hash[:16])
+	}
+	return escaped
+}
+
+func callID(name string) string {
+	var callid strings.Builder
+	for pos := 0; pos < len(name); pos++ {
+		if name[pos] == '%' && pos+2 < len(name) {
+			var b byte
+			if _, err := fmt.Sscanf(name[pos+1:pos+3], "%02x", &b); err == nil {
+				callid.WriteByte(b)
+				pos += 2
+				continue
+			}
+		}
+		callid.WriteByte(name[pos])
+	}
+	return callid.String()
+}
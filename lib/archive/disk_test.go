@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package archive
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+func TestDisk(t *testing.T) {
+	path := t.TempDir()
+	disk, err := OpenDisk(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 6, 1, 12, 59, 0, 0, time.UTC)
+	add := func(callid string, offset time.Duration, line string) {
+		err := disk.Add(&Message{
+			Time:     start.Add(offset),
+			CallID:   callid,
+			Incoming: true,
+			Source:   "10.0.0.2:5060",
+			Target:   "10.0.0.1:5060",
+			Data:     []byte(line + "\r\nCall-ID: " + callid + "\r\n\r\n"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("a/1@host", 0, "INVITE sip:100@example.com SIP/2.0")
+	add("b", 30*time.Second, "INVITE sip:200@example.com SIP/2.0")
+	add("a/1@host", 2*time.Minute, "BYE sip:100@example.com SIP/2.0")
+	add("c", 3*time.Hour, "INVITE sip:300@example.com SIP/2.0")
+	disk.Link("call", "a/1@host")
+	disk.Link("call", "b")
+
+	if len(disk.files) != 4 { // three calls and one links file
+		t.Errorf("Expected 4 files kept open, but got %d", len(disk.files))
+	}
+	if err := disk.Close(); err != nil || len(disk.files) != 0 {
+		t.Fatalf("Expected files closed, but %d open %v", len(disk.files), err)
+	}
+
+	// reopen to check the index is rebuilt from files
+	disk, err = OpenDisk(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := disk.Call("call")
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, but got %d %v", len(messages), err)
+	}
+	if messages[2].Summary() != "BYE sip:100@example.com SIP/2.0" || messages[0].CallID != "a/1@host" {
+		t.Errorf("Unexpected messages %s %s", messages[0].CallID, messages[2].Summary())
+	}
+	if calls := disk.Calls(); len(calls) != 3 || calls["b"] != "call" {
+		t.Errorf("Unexpected calls %v", calls)
+	}
+
+	// age removes the first hour only
+	disk.Age = 2 * time.Hour
+	removed, err := disk.Prune(start.Add(3 * time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 hour removed, but got %d %v", removed, err)
+	}
+	if messages, _ := disk.Call("call"); len(messages) != 0 {
+		t.Errorf("Expected pruned call, but got %d messages", len(messages))
+	}
+	if messages, _ := disk.Call("c"); len(messages) != 1 {
+		t.Errorf("Expected call c kept, but got %d messages", len(messages))
+	}
+
+	// size never removes the current hour
+	disk.Size = 1
+	if removed, _ := disk.Prune(start.Add(3 * time.Hour)); removed != 0 {
+		t.Errorf("Expected current hour kept, but %d removed", removed)
+	}
+	if removed, _ := disk.Prune(start.Add(5 * time.Hour)); removed != 1 || disk.Used() != 0 {
+		t.Errorf("Expected last hour removed, but %d removed with %d used", removed, disk.Used())
+	}
+}
+
+func TestDiskLongCall(t *testing.T) {
+	path := t.TempDir()
+	disk, err := OpenDisk(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	callid := strings.Repeat("a/", 200) + "@host" // escapes past name_max
+	for _, offset := range []time.Duration{0, 210 * time.Minute} {
+		err := disk.Add(&Message{Time: start.Add(offset), CallID: callid, Data: []byte("INFO sip:100@example.com SIP/2.0\r\n\r\n")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	disk.Close()
+
+	// reopen to check the hashed name gives back its call id
+	disk, err = OpenDisk(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages, err := disk.Call(callid); err != nil || len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, but got %d %v", len(messages), err)
+	}
+
+	// a call still written this hour keeps the hour it started in
+	disk.Age = time.Hour
+	if removed, _ := disk.Prune(start.Add(225 * time.Minute)); removed != 0 {
+		t.Errorf("Expected running call kept, but %d removed", removed)
+	}
+	if removed, _ := disk.Prune(start.Add(5 * time.Hour)); removed != 1 {
+		t.Errorf("Expected ended call removed, but %d removed", removed)
+	}
+}
+
+func TestExport(t *testing.T) {
+	messages := []*Message{
+		{Time: time.Unix(1000, 0), Source: "10.0.0.2:5060", Target: "10.0.0.1:5062", Data: []byte("INVITE sip:100@example.com SIP/2.0\r\n\r\n")},
+		{Time: time.Unix(1001, 0), Source: "[2001:db8::1]:5060", Target: "[2001:db8::2]:5060", Data: []byte("SIP/2.0 200 OK\r\n\r\n")},
+	}
+	var text strings.Builder
+	if err := WriteText(&text, messages); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "10.0.0.2:5060 -> 10.0.0.1:5062\nINVITE") {
+		t.Errorf("Unexpected text %s", text.String())
+	}
+
+	var capture bytes.Buffer
+	if err := WritePcap(&capture, messages); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := pcapgo.NewReader(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		data, info, err := reader.ReadPacketData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Timestamp.Equal(message.Time) {
+			t.Errorf("Expected %v, but got %v", message.Time, info.Timestamp)
+		}
+		layer := layers.LayerTypeIPv4
+		if data[0]>>4 == 6 {
+			layer = layers.LayerTypeIPv6
+		}
+		packet := gopacket.NewPacket(data, layer, gopacket.Default)
+		udp, _ := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if udp == nil || !bytes.Equal(udp.Payload, message.Data) {
+			t.Errorf("Unexpected packet %v", packet)
+		}
+	}
+	if _, _, err := reader.ReadPacketData(); err != io.EOF {
+		t.Errorf("Expected end of capture, but got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package archive
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// WriteText writes messages as they would appear in a sip trace
+func WriteText(output io.Writer, messages []*Message) error {
+	for _, message := range messages {
+		_, err := fmt.Fprintf(output, "%s %s -> %s\n%s\n", message.Time.Format("2006-01-02 15:04:05.000000"), message.Source, message.Target, message.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePcap writes messages as udp packets of a raw ip capture
+func WritePcap(output io.Writer, messages []*Message) error {
+	writer := pcapgo.NewWriter(output)
+	if err := writer.WriteFileHeader(65535, layers.LinkTypeRaw); err != nil {
+		return err
+	}
+	options := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	for _, message := range messages {
+		source, sport, err := splitAddress(message.Source)
+		if err != nil {
+			return err
+		}
+		target, tport, err := splitAddress(message.Target)
+		if err != nil {
+			return err
+		}
+
+		udp := &layers.UDP{SrcPort: layers.UDPPort(sport), DstPort: layers.UDPPort(tport)}
+		var network gopacket.SerializableLayer
+		if source.To4() != nil && target.To4() != nil {
+			ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: source.To4(), DstIP: target.To4()}
+			udp.SetNetworkLayerForChecksum(ip)
+			network = ip
+		} else {
+			ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: source, DstIP: target}
+			udp.SetNetworkLayerForChecksum(ip)
+			network = ip
+		}
+
+		buffer := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buffer, options, network, udp, gopacket.Payload(message.Data)); err != nil {
+			return err
+		}
+		data := buffer.Bytes()
+		info := gopacket.CaptureInfo{Timestamp: message.Time, CaptureLength: len(data), Length: len(data)}
+		if err := writer.WritePacket(info, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitAddress(address string) (net.IP, uint16, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("invalid address %s", address)
+	}
+	value, err := strconv.ParseUint(port, 10, 16)
+	return ip, uint16(value), err
+}
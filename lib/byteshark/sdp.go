@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package byteshark
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+)
+
+type SDPMedia struct {
+	Type      string
+	Port      uint16
+	Proto     string
+	Formats   []string
+	Address   net.IP            // media connection if given
+	Direction string            // media direction attribute if given
+	Rtpmap    map[string]string // payload type to encoding/rate
+}
+
+type SDP struct {
+	Address   net.IP // session connection address
+	Direction string // session direction attribute, sendrecv if none
+	Media     []SDPMedia
+}
+
+func ParseSDP(body []byte) *SDP {
+	sdp := &SDP{Direction: "sendrecv"}
+	var media *SDPMedia
+	for len(body) > 0 {
+		line := body
+		if end := bytes.IndexByte(body, '\n'); end > -1 {
+			line = body[:end]
+			body = body[end+1:]
+		} else {
+			body = nil
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+
+		value := line[2:]
+		switch line[0] {
+		case 'c':
+			addr := parseConnection(value)
+			if media != nil {
+				media.Address = addr
+			} else {
+				sdp.Address = addr
+			}
+		case 'm':
+			fields := bytes.Fields(value)
+			if len(fields) < 3 {
+				media = nil
+				continue
+			}
+			port, _ := strconv.Atoi(string(bytes.SplitN(fields[1], []byte("/"), 2)[0]))
+			sdp.Media = append(sdp.Media, SDPMedia{
+				Type:  string(fields[0]),
+				Port:  uint16(port),
+				Proto: string(fields[2]),
+			})
+			media = &sdp.Media[len(sdp.Media)-1]
+			for _, format := range fields[3:] {
+				media.Formats = append(media.Formats, string(format))
+			}
+		case 'a':
+			if media != nil && bytes.HasPrefix(value, []byte("rtpmap:")) {
+				payload, encoding := SplitKeypair(value[7:], ' ')
+				if payload != nil {
+					if media.Rtpmap == nil {
+						media.Rtpmap = make(map[string]string)
+					}
+					media.Rtpmap[string(payload)] = string(encoding)
+				}
+				continue
+			}
+			switch string(value) {
+			case "sendrecv", "sendonly", "recvonly", "inactive":
+				if media != nil {
+					media.Direction = string(value)
+				} else {
+					sdp.Direction = string(value)
+				}
+			}
+		}
+	}
+	return sdp
+}
+
+// Audio returns first active audio stream
+func (sdp *SDP) Audio() *SDPMedia {
+	for pos := range sdp.Media {
+		if sdp.Media[pos].Type == "audio" && sdp.Media[pos].Port > 0 {
+			return &sdp.Media[pos]
+		}
+	}
+	return nil
+}
+
+// static rtp payload types of rfc 3551
+var staticPayloads = map[string]string{
+	"0":  "PCMU/8000",
+	"3":  "GSM/8000",
+	"4":  "G723/8000",
+	"8":  "PCMA/8000",
+	"9":  "G722/8000",
+	"18": "G729/8000",
+}
+
+// Encoding returns encoding/rate of a payload type of a media stream
+func (media *SDPMedia) Encoding(payload string) string {
+	if encoding, found := media.Rtpmap[payload]; found {
+		return encoding
+	}
+	return staticPayloads[payload]
+}
+
+// Codec returns encoding name of the preferred, first, format
+func (media *SDPMedia) Codec() string {
+	if len(media.Formats) == 0 {
+		return ""
+	}
+	encoding := media.Encoding(media.Formats[0])
+	if pos := strings.IndexByte(encoding, '/'); pos > -1 {
+		encoding = encoding[:pos]
+	}
+	return encoding
+}
+
+// Held tests if sdp offer places the other party on hold
+func (sdp *SDP) Held() bool {
+	audio := sdp.Audio()
+	if audio == nil {
+		return false
+	}
+
+	address := sdp.Address
+	if audio.Address != nil {
+		address = audio.Address
+	}
+	if address != nil && address.IsUnspecified() {
+		return true // rfc 2543 style hold
+	}
+
+	direction := sdp.Direction
+	if len(audio.Direction) > 0 {
+		direction = audio.Direction
+	}
+	return direction == "sendonly" || direction == "inactive"
+}
+
+func parseConnection(value []byte) net.IP {
+	fields := bytes.Fields(value)
+	if len(fields) < 3 {
+		return nil
+	}
+	addr := fields[2]
+	if pos := bytes.IndexByte(addr, '/'); pos > -1 {
+		addr = addr[:pos] // strip multicast ttl
+	}
+	return net.ParseIP(string(addr))
+}
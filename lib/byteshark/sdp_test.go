@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package byteshark
+
+import (
+	"testing"
+)
+
+func TestParseSDP(t *testing.T) {
+	body := []byte("v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\ns=-\r\nc=IN IP4 10.0.0.1\r\nt=0 0\r\nm=audio 4000 RTP/AVP 0 8 101\r\na=sendrecv\r\n")
+	sdp := ParseSDP(body)
+	audio := sdp.Audio()
+	if audio == nil || audio.Port != 4000 || len(audio.Formats) != 3 {
+		t.Fatalf("Expected audio stream, got %+v", sdp.Media)
+	}
+	if !sdp.Address.Equal([]byte{10, 0, 0, 1}) {
+		t.Errorf("Expected 10.0.0.1, but got %v", sdp.Address)
+	}
+	if sdp.Held() {
+		t.Errorf("Expected active media")
+	}
+	if codec := audio.Codec(); codec != "PCMU" {
+		t.Errorf("Expected PCMU, but got %s", codec)
+	}
+}
+
+func TestSDPCodec(t *testing.T) {
+	body := []byte("c=IN IP4 10.0.0.1\r\nm=audio 4000 RTP/AVP 96 8 101\r\na=rtpmap:96 opus/48000/2\r\na=rtpmap:101 telephone-event/8000\r\n")
+	audio := ParseSDP(body).Audio()
+	if codec := audio.Codec(); codec != "opus" {
+		t.Errorf("Expected opus, but got %s", codec)
+	}
+	if encoding := audio.Encoding("8"); encoding != "PCMA/8000" {
+		t.Errorf("Expected PCMA/8000, but got %s", encoding)
+	}
+}
+
+func TestSDPHeld(t *testing.T) {
+	tests := map[string]bool{
+		"c=IN IP4 10.0.0.1\r\nm=audio 4000 RTP/AVP 0\r\na=sendonly\r\n": true,
+		"c=IN IP4 10.0.0.1\r\nm=audio 4000 RTP/AVP 0\r\na=inactive\r\n": true,
+		"c=IN IP4 10.0.0.1\r\nm=audio 4000 RTP/AVP 0\r\na=recvonly\r\n": false,
+		"c=IN IP4 0.0.0.0\r\nm=audio 4000 RTP/AVP 0\r\n":                true,
+		"a=sendonly\r\nc=IN IP4 10.0.0.1\r\nm=audio 4000 RTP/AVP 0\r\n": true,
+		"a=sendonly\r\nm=audio 4000 RTP/AVP 0\r\na=sendrecv\r\n":        false,
+		"c=IN IP4 10.0.0.1\r\nm=audio 0 RTP/AVP 0\r\na=sendonly\r\n":    false,
+	}
+	for body, expected := range tests {
+		if held := ParseSDP([]byte(body)).Held(); held != expected {
+			t.Errorf("Expected %v for %q", expected, body)
+		}
+	}
+}
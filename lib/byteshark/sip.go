@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package byteshark
+
+import (
+	"bytes"
+)
+
+// MatchHeader matches a header key by full or compact name
+func MatchHeader(key []byte, name, compact string) bool {
+	if len(compact) > 0 && len(key) == len(compact) && bytes.EqualFold(key, []byte(compact)) {
+		return true
+	}
+	return len(key) == len(name) && bytes.EqualFold(key, []byte(name))
+}
+
+// ParseCSeq splits a cseq header into sequence and method
+func ParseCSeq(value []byte) (uint32, []byte) {
+	var seq uint32
+	pos := 0
+	for pos < len(value) && value[pos] >= '0' && value[pos] <= '9' {
+		seq = seq*10 + uint32(value[pos]-'0')
+		pos++
+	}
+	return seq, bytes.TrimSpace(value[pos:])
+}
+
+// ParseParam finds a ;name=value parameter outside of any <uri>
+func ParseParam(value []byte, name string) []byte {
+	if end := bytes.LastIndexByte(value, '>'); end > -1 {
+		value = value[end+1:]
+	}
+	for len(value) > 0 {
+		pos := bytes.IndexByte(value, ';')
+		if pos < 0 {
+			return nil
+		}
+		value = value[pos+1:]
+		param := value
+		if next := bytes.IndexByte(param, ';'); next > -1 {
+			param = param[:next]
+		}
+		key, val := SplitKeypair(param, '=')
+		if key == nil {
+			key = bytes.TrimSpace(param)
+		}
+		if len(key) == len(name) && bytes.EqualFold(key, []byte(name)) {
+			return bytes.Trim(val, "\"")
+		}
+	}
+	return nil
+}
+
+// ParseContentType returns media type without parameters
+func ParseContentType(value []byte) []byte {
+	if pos := bytes.IndexByte(value, ';'); pos > -1 {
+		value = value[:pos]
+	}
+	return bytes.TrimSpace(value)
+}
+
+// ParseURI returns the uri of a name-addr or addr-spec header value
+func ParseURI(value []byte) []byte {
+	if start := bytes.IndexByte(value, '<'); start > -1 {
+		end := bytes.IndexByte(value[start:], '>')
+		if end < 0 {
+			return nil
+		}
+		return value[start+1 : start+end]
+	}
+	if pos := bytes.IndexByte(value, ';'); pos > -1 {
+		value = value[:pos]
+	}
+	return bytes.TrimSpace(value)
+}
+
+// SplitURIHeaders separates ?headers from a uri
+func SplitURIHeaders(uri []byte) (addr, headers []byte) {
+	if pos := bytes.IndexByte(uri, '?'); pos > -1 {
+		return uri[:pos], uri[pos+1:]
+	}
+	return uri, nil
+}
+
+// ParseURIUser returns user part of a sip or tel uri
+func ParseURIUser(uri []byte) []byte {
+	tel := MatchKeyword(uri, []byte("tel:"))
+	if pos := bytes.IndexByte(uri, ':'); pos > -1 {
+		uri = uri[pos+1:]
+	}
+	if pos := bytes.IndexByte(uri, '@'); pos > -1 {
+		uri = uri[:pos]
+	} else if !tel {
+		return nil // host only
+	}
+	if pos := bytes.IndexAny(uri, ";?"); pos > -1 {
+		uri = uri[:pos]
+	}
+	return uri
+}
+
+// ParseSipfrag returns the status line code of a message/sipfrag body
+func ParseSipfrag(body []byte) int {
+	if !bytes.HasPrefix(body, []byte("SIP/")) {
+		return 0
+	}
+	fields := bytes.Fields(body)
+	if len(fields) < 2 {
+		return 0
+	}
+	status := 0
+	for _, b := range fields[1] {
+		if b < '0' || b > '9' {
+			return 0
+		}
+		status = status*10 + int(b-'0')
+	}
+	return status
+}
+
+// ParseDelta returns leading delta-seconds of a header value
+func ParseDelta(value []byte) int {
+	delta := 0
+	for _, b := range bytes.TrimSpace(value) {
+		if b < '0' || b > '9' {
+			break
+		}
+		delta = delta*10 + int(b-'0')
+	}
+	return delta
+}
+
+// ParseRAck splits a rack header into rseq, cseq and method
+func ParseRAck(value []byte) (rseq, cseq uint32, method []byte) {
+	fields := bytes.Fields(value)
+	if len(fields) != 3 {
+		return 0, 0, nil
+	}
+	return uint32(ParseDelta(fields[0])), uint32(ParseDelta(fields[1])), fields[2]
+}
+
+// SplitList splits comma separated header values outside of <> and quotes
+func SplitList(value []byte, out *[]([]byte)) int {
+	count := 0
+	start := 0
+	angle, quote := false, false
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			switch value[i] {
+			case '"':
+				quote = !quote
+				continue
+			case '<':
+				angle = !quote
+				continue
+			case '>':
+				angle = false
+				continue
+			case ',':
+				if angle || quote {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		item := bytes.TrimSpace(value[start:i])
+		start = i + 1
+		if len(item) == 0 {
+			continue
+		}
+		if len(*out) >= cap(*out) {
+			break
+		}
+		*out = append(*out, item)
+		count++
+	}
+	return count
+}
+
+// ParseSessionID returns the rfc 7989 sender and remote uuids, the null
+// uuid of a peer not yet known is returned as empty
+func ParseSessionID(value []byte) (uuid, remote []byte) {
+	uuid = value
+	if pos := bytes.IndexByte(value, ';'); pos > -1 {
+		uuid = value[:pos]
+	}
+	uuid = bytes.TrimSpace(uuid)
+	remote = ParseParam(value, "remote")
+	if bytes.Count(uuid, []byte("0")) == len(uuid) {
+		uuid = nil
+	}
+	if bytes.Count(remote, []byte("0")) == len(remote) {
+		remote = nil
+	}
+	return uuid, remote
+}
+
+// ParseDisplayName returns the display name of a name-addr
+func ParseDisplayName(value []byte) []byte {
+	value = bytes.TrimSpace(value)
+	if len(value) > 0 && value[0] == '"' {
+		if end := bytes.IndexByte(value[1:], '"'); end > -1 {
+			return value[1 : end+1]
+		}
+		return nil
+	}
+	if pos := bytes.IndexByte(value, '<'); pos > 0 {
+		return bytes.TrimSpace(value[:pos])
+	}
+	return nil
+}
+
+// NormalizeUser decodes escapes and strips visual separators of numbers
+func NormalizeUser(user []byte) string {
+	out := make([]byte, 0, len(user))
+	for i := 0; i < len(user); i++ {
+		b := user[i]
+		if b == '%' && i+2 < len(user) {
+			if value, ok := unhex(user[i+1], user[i+2]); ok {
+				b = value
+				i += 2
+			}
+		}
+		out = append(out, b)
+	}
+
+	digits := make([]byte, 0, len(out))
+	for _, b := range out {
+		switch {
+		case b >= '0' && b <= '9', b == '+', b == '*', b == '#':
+			digits = append(digits, b)
+		case b == '-', b == '.', b == '(', b == ')', b == ' ':
+		default:
+			return string(out) // not a phone number
+		}
+	}
+	return string(digits)
+}
+
+func unhex(high, low byte) (byte, bool) {
+	var value byte
+	for _, c := range []byte{high, low} {
+		value <<= 4
+		switch {
+		case c >= '0' && c <= '9':
+			value |= c - '0'
+		case c >= 'a' && c <= 'f':
+			value |= c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			value |= c - 'A' + 10
+		default:
+			return 0, false
+		}
+	}
+	return value, true
+}
+
+// ParseURIHost returns host part of a sip uri without port or params
+func ParseURIHost(uri []byte) []byte {
+	if MatchKeyword(uri, []byte("tel:")) {
+		return nil
+	}
+	if pos := bytes.IndexByte(uri, ':'); pos > -1 {
+		uri = uri[pos+1:]
+	}
+	if pos := bytes.IndexByte(uri, '@'); pos > -1 {
+		uri = uri[pos+1:]
+	}
+	if pos := bytes.IndexAny(uri, ";?>"); pos > -1 {
+		uri = uri[:pos]
+	}
+	if len(uri) > 0 && uri[0] == '[' {
+		if end := bytes.IndexByte(uri, ']'); end > -1 {
+			return uri[1:end]
+		}
+	}
+	if pos := bytes.IndexByte(uri, ':'); pos > -1 {
+		uri = uri[:pos]
+	}
+	return uri
+}
+
+// ParseReason splits an rfc 3326 reason into protocol, cause, and text
+func ParseReason(value []byte) (protocol []byte, cause int, text []byte) {
+	protocol = value
+	if pos := bytes.IndexByte(value, ';'); pos > -1 {
+		protocol = value[:pos]
+	}
+	protocol = bytes.TrimSpace(protocol)
+	cause = ParseDelta(ParseParam(value, "cause"))
+	text = ParseParam(value, "text")
+	return protocol, cause, text
+}
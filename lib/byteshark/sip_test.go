@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package byteshark
+
+import (
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	value := []byte(`"Bob" <sip:200@pbx.example.com?Replaces=abc%40host%3Bto-tag%3D1>;tag=xyz`)
+	uri := ParseURI(value)
+	addr, headers := SplitURIHeaders(uri)
+	if string(addr) != "sip:200@pbx.example.com" {
+		t.Errorf("Expected sip:200@pbx.example.com, but got %s", addr)
+	}
+	if string(headers) != "Replaces=abc%40host%3Bto-tag%3D1" {
+		t.Errorf("Unexpected uri headers %s", headers)
+	}
+	if user := ParseURIUser(addr); string(user) != "200" {
+		t.Errorf("Expected 200, but got %s", user)
+	}
+	if tag := ParseParam(value, "tag"); string(tag) != "xyz" {
+		t.Errorf("Expected xyz, but got %s", tag)
+	}
+	if user := ParseURIUser([]byte("sip:pbx.example.com")); user != nil {
+		t.Errorf("Expected no user, but got %s", user)
+	}
+	if user := ParseURIUser([]byte("tel:+15551234;phone-context=example.com")); string(user) != "+15551234" {
+		t.Errorf("Expected +15551234, but got %s", user)
+	}
+	if host := ParseURIHost(addr); string(host) != "pbx.example.com" {
+		t.Errorf("Expected pbx.example.com, but got %s", host)
+	}
+	if host := ParseURIHost([]byte("sip:100@[2001:db8::1]:5060;transport=tcp")); string(host) != "2001:db8::1" {
+		t.Errorf("Expected 2001:db8::1, but got %s", host)
+	}
+}
+
+func TestParseSipfrag(t *testing.T) {
+	if status := ParseSipfrag([]byte("SIP/2.0 180 Ringing\r\n")); status != 180 {
+		t.Errorf("Expected 180, but got %d", status)
+	}
+	if status := ParseSipfrag([]byte("INVITE sip:a@b SIP/2.0")); status != 0 {
+		t.Errorf("Expected 0, but got %d", status)
+	}
+}
+
+func TestParseSessionID(t *testing.T) {
+	uuid, remote := ParseSessionID([]byte("ab30317f1a784dc48ff824d0d3715d86;remote=00000000000000000000000000000000"))
+	if string(uuid) != "ab30317f1a784dc48ff824d0d3715d86" || remote != nil {
+		t.Errorf("Unexpected session id %s remote %s", uuid, remote)
+	}
+	uuid, remote = ParseSessionID([]byte("47755a9de7794ba387653f2099600ef2 ;remote=ab30317f1a784dc48ff824d0d3715d86"))
+	if string(uuid) != "47755a9de7794ba387653f2099600ef2" || string(remote) != "ab30317f1a784dc48ff824d0d3715d86" {
+		t.Errorf("Unexpected session id %s remote %s", uuid, remote)
+	}
+}
+
+func TestNormalizeUser(t *testing.T) {
+	tests := map[string]string{
+		"+1-555-123.4567": "+15551234567",
+		"%2B4930123":      "+4930123",
+		"(555) 1234":      "5551234",
+		"alice":           "alice",
+		"*72#":            "*72#",
+	}
+	for user, expected := range tests {
+		if result := NormalizeUser([]byte(user)); result != expected {
+			t.Errorf("Expected %s for %s, but got %s", expected, user, result)
+		}
+	}
+	if name := ParseDisplayName([]byte(`"Doe, John" <sip:100@host>`)); string(name) != "Doe, John" {
+		t.Errorf("Unexpected display name %s", name)
+	}
+	if name := ParseDisplayName([]byte(`Alice <sip:alice@host>`)); string(name) != "Alice" {
+		t.Errorf("Unexpected display name %s", name)
+	}
+}
+
+func TestParseReason(t *testing.T) {
+	reasons := make([][]byte, 0, 4)
+	value := []byte(`Q.850;cause=16;text="Normal call clearing", SIP ;cause=200;text="Call completed elsewhere"`)
+	if count := SplitList(value, &reasons); count != 2 {
+		t.Fatalf("Expected 2 reasons, but got %d", count)
+	}
+	protocol, cause, text := ParseReason(reasons[0])
+	if string(protocol) != "Q.850" || cause != 16 || string(text) != "Normal call clearing" {
+		t.Errorf("Unexpected reason %s %d %s", protocol, cause, text)
+	}
+	protocol, cause, _ = ParseReason(reasons[1])
+	if string(protocol) != "SIP" || cause != 200 {
+		t.Errorf("Unexpected reason %s %d", protocol, cause)
+	}
+}
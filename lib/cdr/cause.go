@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+// Disconnect is which side ended a leg and the cause it gave
+type Disconnect struct {
+	Side   string `json:"side,omitempty"` // local or remote, none if expired
+	By     string `json:"by"`             // bye, cancel, response, or expired
+	SIP    int    `json:"sip,omitempty"`  // sip reason cause or final status
+	Q850   int    `json:"q850"`
+	Text   string `json:"text,omitempty"`
+	Mapped bool   `json:"mapped,omitempty"` // q850 derived from sip status
+}
+
+const (
+	ByBye      = "bye"
+	ByCancel   = "cancel"
+	ByResponse = "response"
+	ByExpiry   = "expired"
+
+	NormalClearing = 16
+	Interworking   = 127
+)
+
+// rfc 3398 section 8.2.6.1 sip status to isup cause
+var sipCauses = map[int]int{
+	400: 41,
+	401: 21,
+	402: 21,
+	403: 21,
+	404: 1,
+	405: 63,
+	406: 79,
+	407: 21,
+	408: 102,
+	410: 22,
+	413: 127,
+	414: 127,
+	415: 79,
+	416: 127,
+	420: 127,
+	421: 127,
+	423: 127,
+	480: 18,
+	481: 41,
+	482: 25,
+	483: 25,
+	484: 28,
+	485: 1,
+	486: 17,
+	500: 41,
+	501: 79,
+	502: 38,
+	503: 41,
+	504: 102,
+	505: 127,
+	513: 127,
+	600: 17,
+	603: 21,
+	604: 1,
+}
+
+// rfc 3398 maps 488 and 606 by the warning code they carry
+var warningCauses = map[int]int{
+	300: 65, // incompatible network protocol
+	301: 65, // incompatible network address formats
+	302: 65, // incompatible transport protocol
+	303: 65, // incompatible bandwidth units
+	304: 65, // media type not available
+	305: 65, // incompatible media format
+	306: 65, // attribute not understood
+	307: 65, // session description parameter not understood
+	370: 58, // insufficient bandwidth
+}
+
+var causeText = map[int]string{
+	1:   "Unallocated number",
+	16:  "Normal call clearing",
+	17:  "User busy",
+	18:  "No user responding",
+	19:  "No answer from user",
+	21:  "Call rejected",
+	22:  "Number changed",
+	25:  "Exchange routing error",
+	26:  "Non-selected user clearing",
+	27:  "Destination out of order",
+	28:  "Invalid number format",
+	31:  "Normal, unspecified",
+	34:  "No circuit/channel available",
+	38:  "Network out of order",
+	41:  "Temporary failure",
+	42:  "Switching equipment congestion",
+	47:  "Resource unavailable, unspecified",
+	58:  "Bearer capability not presently available",
+	63:  "Service or option not available",
+	65:  "Bearer capability not implemented",
+	79:  "Service or option not implemented",
+	102: "Recovery on timer expiry",
+	127: "Interworking, unspecified",
+}
+
+// Q850 maps a sip status to an isup cause per rfc 3398
+func Q850(status int) int {
+	if status < 300 {
+		return NormalClearing
+	}
+	if cause, found := sipCauses[status]; found {
+		return cause
+	}
+	return Interworking
+}
+
+// Q850Warning maps a sip status and its warning code to an isup cause,
+// the warning deciding for 488 and 606
+func Q850Warning(status, warning int) int {
+	if status == 488 || status == 606 {
+		if cause, found := warningCauses[warning]; found {
+			return cause
+		}
+	}
+	return Q850(status)
+}
+
+// CauseText describes a q.850 cause
+func CauseText(cause int) string {
+	return causeText[cause]
+}
+
+// UserCause tests if a q.850 cause is from the called user rather than
+// a network failure, which counts towards network effectiveness
+func UserCause(cause int) bool {
+	switch cause {
+	case 16, 17, 18, 19, 21, 31:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import "testing"
+
+func TestQ850(t *testing.T) {
+	tests := map[int]int{
+		200: 16,
+		404: 1,
+		408: 102,
+		480: 18,
+		486: 17,
+		487: 127,
+		488: 127,
+		503: 41,
+		603: 21,
+		606: 127,
+	}
+	for status, cause := range tests {
+		if mapped := Q850(status); mapped != cause {
+			t.Errorf("Expected cause %d for %d, but got %d", cause, status, mapped)
+		}
+	}
+	warnings := []struct{ status, warning, cause int }{
+		{488, 305, 65},
+		{606, 305, 65},
+		{488, 370, 58},
+		{606, 370, 58},
+		{606, 399, 127},
+		{486, 370, 17},
+	}
+	for _, test := range warnings {
+		if mapped := Q850Warning(test.status, test.warning); mapped != test.cause {
+			t.Errorf("Expected cause %d for %d with %d, but got %d", test.cause, test.status, test.warning, mapped)
+		}
+	}
+	if text := CauseText(17); text != "User busy" {
+		t.Errorf("Unexpected text %s", text)
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"sort"
+	"time"
+)
+
+// Hop is a distinct leg of a call and the legs it led to
+type Hop struct {
+	Record   *Record  `json:"leg"`
+	Nodes    []string `json:"nodes"`              // nodes that saw this leg
+	Sender   string   `json:"sender,omitempty"`   // node that sent the invite
+	Receiver string   `json:"receiver,omitempty"` // node the invite arrived at
+	Setup    int64    `json:"setup_ms"`           // invite sent after parent leg arrived
+	Answer   int64    `json:"answer_ms"`          // parent answered after this leg was
+	Children []*Hop   `json:"children,omitempty"`
+}
+
+// Call is every leg with one collation id merged across nodes
+type Call struct {
+	Collated string    `json:"collated"`
+	Nodes    []string  `json:"nodes"`
+	Legs     int       `json:"legs"`
+	Created  time.Time `json:"created"`
+	Answered time.Time `json:"answered"`
+	Finished time.Time `json:"finished"`
+	Root     *Hop      `json:"root"`
+}
+
+// how far apart the same leg may be seen by two nodes
+const mergeWindow = 32 * time.Second
+
+// Merge groups records into calls rooted at the initiating incoming leg
+func Merge(records []*Record) []*Call {
+	grouped := make(map[string][]*Record)
+	var order []string
+	for _, record := range records {
+		if _, ok := grouped[record.Collated]; !ok {
+			order = append(order, record.Collated)
+		}
+		grouped[record.Collated] = append(grouped[record.Collated], record)
+	}
+
+	calls := make([]*Call, 0, len(order))
+	for _, collated := range order {
+		calls = append(calls, mergeCall(collated, grouped[collated]))
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Created.Before(calls[j].Created)
+	})
+	return calls
+}
+
+func mergeCall(collated string, records []*Record) *Call {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Created.Before(records[j].Created)
+	})
+
+	// dedupe legs seen from both ends by different nodes
+	var hops []*Hop
+	seen := make(map[string][]*Hop)
+	for _, record := range records {
+		key := legKey(record)
+		var hop *Hop
+		for _, prior := range seen[key] {
+			if !contains(prior.Nodes, record.Node) && absolute(record.Created.Sub(prior.Record.Created)) <= mergeWindow {
+				hop = prior
+				break
+			}
+		}
+		if hop == nil {
+			hop = &Hop{Record: record}
+			seen[key] = append(seen[key], hop)
+			hops = append(hops, hop)
+		}
+		hop.Nodes = append(hop.Nodes, record.Node)
+		if record.Incoming {
+			hop.Receiver = record.Node
+			hop.Record = record // prefer view of node it arrived at
+		} else {
+			hop.Sender = record.Node
+		}
+	}
+
+	call := &Call{Collated: collated, Legs: len(hops)}
+	for _, hop := range hops {
+		for _, node := range hop.Nodes {
+			if !contains(call.Nodes, node) {
+				call.Nodes = append(call.Nodes, node)
+			}
+		}
+	}
+
+	// a leg sent by a node hangs off the latest leg that arrived there before it
+	var orphans []*Hop
+	for pos, hop := range hops {
+		var parent *Hop
+		if len(hop.Sender) > 0 {
+			for _, prior := range hops[:pos] {
+				if prior.Receiver == hop.Sender {
+					parent = prior
+				}
+			}
+		}
+		if parent == nil {
+			orphans = append(orphans, hop)
+			continue
+		}
+		hop.Setup = hop.Record.Created.Sub(parent.Record.Created).Milliseconds()
+		if hop.Record.Connected && parent.Record.Connected {
+			hop.Answer = parent.Record.Answered.Sub(hop.Record.Answered).Milliseconds()
+		}
+		parent.Children = append(parent.Children, hop)
+	}
+
+	for _, hop := range orphans {
+		if call.Root == nil || (hop.Record.Incoming && !call.Root.Record.Incoming) {
+			call.Root = hop
+		}
+	}
+	for _, hop := range orphans {
+		if hop != call.Root {
+			hop.Setup = hop.Record.Created.Sub(call.Root.Record.Created).Milliseconds()
+			call.Root.Children = append(call.Root.Children, hop)
+		}
+	}
+
+	call.Created = call.Root.Record.Created
+	call.Answered = call.Root.Record.Answered
+	for _, hop := range hops {
+		if hop.Record.Finished.After(call.Finished) {
+			call.Finished = hop.Record.Finished
+		}
+	}
+	return call
+}
+
+// Walk visits hops depth first with their depth in the call tree
+func (call *Call) Walk(visit func(hop *Hop, depth int)) {
+	var walk func(hop *Hop, depth int)
+	walk = func(hop *Hop, depth int) {
+		visit(hop, depth)
+		for _, child := range hop.Children {
+			walk(child, depth+1)
+		}
+	}
+	if call.Root != nil {
+		walk(call.Root, 0)
+	}
+}
+
+func legKey(record *Record) string {
+	local, remote := record.LocalTag, record.RemoteTag
+	if remote < local {
+		local, remote = remote, local
+	}
+	return record.CallID + "/" + local + "/" + remote
+}
+
+func contains(list []string, item string) bool {
+	for _, entry := range list {
+		if entry == item {
+			return true
+		}
+	}
+	return false
+}
+
+func absolute(delta time.Duration) time.Duration {
+	if delta < 0 {
+		return -delta
+	}
+	return delta
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeNode(t *testing.T, path string, records ...*Record) {
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	for _, record := range records {
+		if err := sink.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Write(map[string]string{"type": "interval"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeNodes(t *testing.T) {
+	start := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	leg := func(node, callid string, incoming bool, offset time.Duration, local, remote string) *Record {
+		return &Record{
+			Type:      LegRecord,
+			Node:      node,
+			Collated:  "call1",
+			CallID:    callid,
+			Incoming:  incoming,
+			Connected: true,
+			Final:     200,
+			Created:   start.Add(offset),
+			Answered:  start.Add(5*time.Second - offset),
+			Finished:  start.Add(time.Minute),
+			LocalTag:  local,
+			RemoteTag: remote,
+		}
+	}
+
+	dir := t.TempDir()
+	sbc := filepath.Join(dir, "sbc.cdr")
+	pbx := filepath.Join(dir, "pbx.cdr")
+	writeNode(t, sbc,
+		leg("sbc", "carrier", true, 0, "s1", "c1"),
+		leg("sbc", "inside", false, 20*time.Millisecond, "s2", "p1"))
+	writeNode(t, pbx,
+		leg("pbx", "inside", true, 21*time.Millisecond, "p1", "s2"),
+		leg("pbx", "phone", false, 50*time.Millisecond, "p2", "h1"))
+
+	var records []*Record
+	for _, path := range []string{pbx, sbc} {
+		found, err := ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, found...)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 leg records, but got %d", len(records))
+	}
+
+	calls := Merge(records)
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 call, but got %d", len(calls))
+	}
+	call := calls[0]
+	if call.Legs != 3 || len(call.Nodes) != 2 {
+		t.Fatalf("Expected 3 legs from 2 nodes, but got %d from %v", call.Legs, call.Nodes)
+	}
+
+	var path []string
+	call.Walk(func(hop *Hop, depth int) {
+		path = append(path, hop.Record.CallID)
+	})
+	if len(path) != 3 || path[0] != "carrier" || path[1] != "inside" || path[2] != "phone" {
+		t.Fatalf("Unexpected call tree %v", path)
+	}
+
+	inside := call.Root.Children[0]
+	if inside.Sender != "sbc" || inside.Receiver != "pbx" || inside.Setup != 21 {
+		t.Errorf("Unexpected hop %s to %s setup %d", inside.Sender, inside.Receiver, inside.Setup)
+	}
+	if phone := inside.Children[0]; phone.Setup != 29 || phone.Answer != 29 {
+		t.Errorf("Expected 29ms setup and answer, but got %d and %d", phone.Setup, phone.Answer)
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReadRecords reads leg records from json lines, other record types are skipped
+func ReadRecords(input io.Reader) ([]*Record, error) {
+	var records []*Record
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		if len(text) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(text, record); err != nil {
+			return records, fmt.Errorf("line %d: %v", line, err)
+		}
+		if record.Type != LegRecord {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func ReadFile(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := ReadRecords(file)
+	if err != nil {
+		return records, fmt.Errorf("%s: %v", path, err)
+	}
+	return records, nil
+}
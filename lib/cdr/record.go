@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"net"
+	"time"
+
+	"spycraft/lib/kpi"
+	"spycraft/lib/service"
+)
+
+// Record is a completed call leg as written to cdr sinks
+type Record struct {
+	Type          string           `json:"type"`
+	Node          string           `json:"node"`
+	Collated      string           `json:"collated"`
+	CallID        string           `json:"callid"`
+	SessionID     string           `json:"session_id,omitempty"` // rfc 7989 uuid of inviter
+	SessionRemote string           `json:"session_remote,omitempty"`
+	Agent         string           `json:"agent,omitempty"`
+	Codec         string           `json:"codec,omitempty"`
+	Caller        Party            `json:"caller"`
+	Called        Party            `json:"called"`
+	Class         string           `json:"class,omitempty"` // of the called number
+	Redirects     []Redirect       `json:"redirects,omitempty"`
+	Trunk         string           `json:"trunk,omitempty"`
+	TrunkClass    string           `json:"trunk_class,omitempty"` // carrier, extension, or peer
+	Original      string           `json:"original_called,omitempty"`
+	Endpoint      net.IP           `json:"endpoint"`
+	Port          uint16           `json:"port"`
+	Incoming      bool             `json:"incoming"`
+	Connected     bool             `json:"connected"`
+	Final         int              `json:"final"`
+	Created       time.Time        `json:"created"`
+	Answered      time.Time        `json:"answered"`
+	Finished      time.Time        `json:"finished"`
+	Duration      service.Duration `json:"duration"`
+	Holds         int              `json:"holds,omitempty"`
+	HoldTime      service.Duration `json:"hold_time,omitempty"`
+	Transfer      *Transfer        `json:"transfer,omitempty"`
+	Dialogs       int              `json:"dialogs"` // early dialogs from forking
+	LocalTag      string           `json:"local_tag,omitempty"`
+	RemoteTag     string           `json:"remote_tag,omitempty"`
+	Expired       string           `json:"expired,omitempty"` // leg ended without bye
+	Disconnect    *Disconnect      `json:"disconnect,omitempty"`
+	Session       service.Duration `json:"session_interval,omitempty"`
+	Refresher     string           `json:"refresher,omitempty"` // local or remote
+	Refreshes     int              `json:"refreshes,omitempty"`
+	MinSE         service.Duration `json:"min_se,omitempty"`
+	PostDial      int64            `json:"pdd_ms,omitempty"`         // invite to first alerting
+	EarlyMedia    int64            `json:"early_media_ms,omitempty"` // early media till answer or end
+	Reliable      int              `json:"reliable,omitempty"`       // 100rel provisionals
+	Pracks        int              `json:"pracks,omitempty"`
+	Updates       int              `json:"early_updates,omitempty"`
+	Rating        *Rating          `json:"rating,omitempty"`
+	Media         []*Quality       `json:"media,omitempty"`
+	Recording     string           `json:"recording,omitempty"` // wav file of the call
+}
+
+// Party is a calling or called identity
+type Party struct {
+	Number  string `json:"number"`
+	E164    string `json:"e164,omitempty"`
+	Name    string `json:"name,omitempty"`
+	URI     string `json:"uri,omitempty"`
+	Private bool   `json:"private,omitempty"` // presentation restricted
+}
+
+// Redirect is a number the call was diverted from, oldest first
+type Redirect struct {
+	Number string `json:"number"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Rating is the cost of a connected leg from a rate deck
+type Rating struct {
+	Deck     string  `json:"deck,omitempty"` // trunk or customer deck, default if empty
+	Prefix   string  `json:"prefix"`
+	Rate     float64 `json:"rate"`   // per minute
+	Billed   int     `json:"billed"` // seconds after rounding to increments
+	Cost     float64 `json:"cost"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+// Quality is the rtp of one direction of a leg as measured on the wire
+type Quality struct {
+	Direction  string    `json:"direction"` // received by local or remote side
+	SSRC       string    `json:"ssrc"`
+	Codec      string    `json:"codec,omitempty"`
+	Packets    uint64    `json:"packets"`
+	Lost       uint64    `json:"lost"`
+	Loss       float64   `json:"loss_pct"`
+	OutOfOrder uint64    `json:"out_of_order,omitempty"`
+	Duplicates uint64    `json:"duplicates,omitempty"`
+	Jitter     float64   `json:"jitter_ms"`
+	MaxDelta   float64   `json:"max_delta_ms"`
+	RFactor    float64   `json:"r_factor"`
+	MOS        float64   `json:"mos"`
+	Reported   *Reported `json:"reported,omitempty"`
+}
+
+// Reported is what the receiving endpoint says of the same media by rtcp
+type Reported struct {
+	CNAME     string  `json:"cname,omitempty"`
+	Reports   int     `json:"reports"`
+	Lost      int32   `json:"lost"`     // cumulative
+	Loss      float64 `json:"loss_pct"` // of the last interval
+	Jitter    float64 `json:"jitter_ms"`
+	RoundTrip float64 `json:"rtt_ms,omitempty"`
+	RFactor   float64 `json:"r_factor,omitempty"` // from rtcp-xr
+	MOS       float64 `json:"mos,omitempty"`      // from rtcp-xr
+	Bye       string  `json:"bye,omitempty"`      // reason if given
+}
+
+// Transfer describes a refer either made on or that created a leg
+type Transfer struct {
+	Kind       string `json:"kind"` // blind or attended
+	Transferor string `json:"transferor"`
+	Transferee string `json:"transferee"`
+	Target     string `json:"target"`
+	Replaces   string `json:"replaces,omitempty"` // replaced dialog call id
+	Original   string `json:"original,omitempty"` // call id of transferred leg
+	Progress   int    `json:"progress,omitempty"` // latest provisional sipfrag status
+	Status     int    `json:"status"`             // final sipfrag or refer status
+}
+
+// Statistics is a kpi summary of one key written at export intervals
+type Statistics struct {
+	Type    string        `json:"type"`
+	Node    string        `json:"node"`
+	Key     string        `json:"key"` // node, trunk, or prefix and direction
+	Time    time.Time     `json:"time"`
+	Windows []kpi.Summary `json:"windows"`
+}
+
+// Interval is peak channels of a node, trunk, or direction over an interval
+type Interval struct {
+	Type      string    `json:"type"`
+	Node      string    `json:"node"`
+	Trunk     string    `json:"trunk,omitempty"`
+	Direction string    `json:"direction,omitempty"` // incoming or outgoing, both if empty
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Peak      int       `json:"peak"`
+	Peaked    time.Time `json:"peaked"`
+	Current   int       `json:"current"` // channels in use at end
+	Limit     int       `json:"limit,omitempty"`
+}
+
+const (
+	LegRecord        = "leg"
+	StatisticsRecord = "kpi"
+	IntervalRecord   = "interval"
+
+	TimedOut       = "timed out"
+	SessionExpired = "session expired"
+
+	BlindTransfer    = "blind"
+	AttendedTransfer = "attended"
+)
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ring keeps the most recent leg records in memory for queries
+type Ring struct {
+	records []*Record
+	next    int
+	count   int
+	lock    sync.Mutex
+}
+
+// Filter selects records, with zero values matching anything
+type Filter struct {
+	From     time.Time // created at or after
+	To       time.Time // created before
+	Number   string    // caller or called number or e164
+	Trunk    string
+	Collated string
+	Status   string // final code such as 486 or class such as 4xx
+}
+
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{records: make([]*Record, size)}
+}
+
+func (ring *Ring) Write(record interface{}) error {
+	leg, ok := record.(*Record)
+	if !ok {
+		return nil
+	}
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	ring.records[ring.next] = leg
+	ring.next = (ring.next + 1) % len(ring.records)
+	if ring.count < len(ring.records) {
+		ring.count++
+	}
+	return nil
+}
+
+// Len is the number of records kept
+func (ring *Ring) Len() int {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	return ring.count
+}
+
+func (ring *Ring) Close() error {
+	return nil
+}
+
+// Recent returns matching records newest first, up to limit if given
+func (ring *Ring) Recent(filter *Filter, limit int) []*Record {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	found := make([]*Record, 0)
+	for pos := 1; pos <= ring.count; pos++ {
+		record := ring.records[(ring.next-pos+len(ring.records))%len(ring.records)]
+		if filter != nil && !filter.Match(record) {
+			continue
+		}
+		found = append(found, record)
+		if limit > 0 && len(found) >= limit {
+			break
+		}
+	}
+	return found
+}
+
+func (filter *Filter) Match(record *Record) bool {
+	if !filter.From.IsZero() && record.Created.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !record.Created.Before(filter.To) {
+		return false
+	}
+	if len(filter.Trunk) > 0 && !strings.EqualFold(filter.Trunk, record.Trunk) {
+		return false
+	}
+	if len(filter.Collated) > 0 && filter.Collated != record.Collated {
+		return false
+	}
+	if len(filter.Number) > 0 && !matchNumber(filter.Number, &record.Caller) && !matchNumber(filter.Number, &record.Called) {
+		return false
+	}
+	if len(filter.Status) > 0 && !matchStatus(filter.Status, record.Final) {
+		return false
+	}
+	return true
+}
+
+func matchNumber(number string, party *Party) bool {
+	return strings.Contains(party.Number, number) || strings.Contains(party.E164, number)
+}
+
+func matchStatus(status string, final int) bool {
+	if len(status) == 3 && strings.HasSuffix(strings.ToLower(status), "xx") {
+		return status[0] >= '1' && status[0] <= '6' && final/100 == int(status[0]-'0')
+	}
+	code, err := strconv.Atoi(status)
+	return err == nil && code == final
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3)
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	finals := []int{200, 486, 200, 404}
+	for pos, final := range finals {
+		ring.Write(&Record{
+			Type:    LegRecord,
+			CallID:  string(rune('a' + pos)),
+			Trunk:   "carrier",
+			Final:   final,
+			Created: start.Add(time.Duration(pos) * time.Minute),
+			Called:  Party{Number: "2125551234", E164: "+12125551234"},
+		})
+	}
+	ring.Write(&Interval{Type: IntervalRecord})
+
+	recent := ring.Recent(nil, 0)
+	if len(recent) != 3 || recent[0].CallID != "d" || recent[2].CallID != "b" {
+		t.Fatalf("Unexpected recent records %+v", recent)
+	}
+	if found := ring.Recent(&Filter{Status: "2xx"}, 0); len(found) != 1 || found[0].CallID != "c" {
+		t.Errorf("Unexpected 2xx records %+v", found)
+	}
+	if found := ring.Recent(&Filter{Status: "486", Number: "+1212"}, 0); len(found) != 1 {
+		t.Errorf("Unexpected 486 records %+v", found)
+	}
+	if found := ring.Recent(&Filter{From: start.Add(2 * time.Minute)}, 1); len(found) != 1 || found[0].CallID != "d" {
+		t.Errorf("Unexpected limited records %+v", found)
+	}
+	if found := ring.Recent(&Filter{Trunk: "other"}, 0); len(found) != 0 {
+		t.Errorf("Expected no records, but got %+v", found)
+	}
+}
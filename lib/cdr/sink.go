@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package cdr
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+type Sink interface {
+	Write(record interface{}) error
+	Close() error
+}
+
+// FileSink appends records as json lines
+type FileSink struct {
+	file    *os.File
+	encoder *json.Encoder
+	lock    sync.Mutex
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (sink *FileSink) Write(record interface{}) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return sink.encoder.Encode(record)
+}
+
+func (sink *FileSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return sink.file.Close()
+}
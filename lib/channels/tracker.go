@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package channels
+
+import (
+	"sort"
+	"time"
+)
+
+// Key groups channels by trunk and direction, empty for the whole node
+type Key struct {
+	Trunk     string
+	Direction string
+}
+
+// Usage is the peak of one key over a finished interval
+type Usage struct {
+	Key
+	Start   time.Time
+	End     time.Time
+	Peak    int
+	Peaked  time.Time
+	Current int // channels in use when the interval ended
+}
+
+type gauge struct {
+	current int
+	peak    int
+	peaked  time.Time
+}
+
+// Tracker counts channels in use and their peak per aligned interval
+type Tracker struct {
+	Interval time.Duration
+	start    time.Time
+	gauges   map[Key]*gauge
+}
+
+const (
+	Incoming = "incoming"
+	Outgoing = "outgoing"
+)
+
+func NewTracker(interval time.Duration) *Tracker {
+	return &Tracker{
+		Interval: interval,
+		gauges:   make(map[Key]*gauge),
+	}
+}
+
+// Seize takes a channel of a key and returns the channels now in use
+func (tracker *Tracker) Seize(key Key, when time.Time) int {
+	tracker.begin(when)
+	found := tracker.gauges[key]
+	if found == nil {
+		found = &gauge{}
+		tracker.gauges[key] = found
+	}
+	found.current++
+	if found.current > found.peak {
+		found.peak = found.current
+		found.peaked = when
+	}
+	return found.current
+}
+
+// Release frees a channel of a key and returns the channels still in use
+func (tracker *Tracker) Release(key Key, when time.Time) int {
+	tracker.begin(when)
+	found := tracker.gauges[key]
+	if found == nil || found.current == 0 {
+		return 0
+	}
+	found.current--
+	return found.current
+}
+
+// Current returns channels in use by a key
+func (tracker *Tracker) Current(key Key) int {
+	if found := tracker.gauges[key]; found != nil {
+		return found.current
+	}
+	return 0
+}
+
+// Roll ends each interval time has passed, returning usage of every key
+// seen, and carries channels still in use into the next interval
+func (tracker *Tracker) Roll(now time.Time) []Usage {
+	var usage []Usage
+	for !tracker.start.IsZero() && !now.Before(tracker.start.Add(tracker.Interval)) {
+		end := tracker.start.Add(tracker.Interval)
+		first := len(usage)
+		for key, found := range tracker.gauges {
+			usage = append(usage, Usage{
+				Key:     key,
+				Start:   tracker.start,
+				End:     end,
+				Peak:    found.peak,
+				Peaked:  found.peaked,
+				Current: found.current,
+			})
+			if found.current == 0 {
+				delete(tracker.gauges, key)
+				continue
+			}
+			found.peak = found.current
+			found.peaked = end
+		}
+		sort.Slice(usage[first:], func(i, j int) bool {
+			left, right := usage[first+i], usage[first+j]
+			if left.Trunk != right.Trunk {
+				return left.Trunk < right.Trunk
+			}
+			return left.Direction < right.Direction
+		})
+		tracker.start = end
+		if len(tracker.gauges) == 0 {
+			tracker.start = now.Truncate(tracker.Interval) // skip idle intervals
+		}
+	}
+	return usage
+}
+
+func (tracker *Tracker) begin(when time.Time) {
+	if tracker.start.IsZero() {
+		tracker.start = when.Truncate(tracker.Interval)
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package channels
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(15 * time.Minute)
+	start := time.Date(2025, 6, 1, 12, 3, 0, 0, time.UTC)
+	carrier := Key{Trunk: "carrier", Direction: Incoming}
+	node := Key{}
+
+	for pos := 0; pos < 3; pos++ {
+		when := start.Add(time.Duration(pos) * time.Minute)
+		tracker.Seize(carrier, when)
+		tracker.Seize(node, when)
+	}
+	tracker.Release(carrier, start.Add(5*time.Minute))
+	tracker.Release(node, start.Add(5*time.Minute))
+	if usage := tracker.Roll(start.Add(10 * time.Minute)); usage != nil {
+		t.Fatalf("Expected no usage before interval ends, but got %v", usage)
+	}
+
+	usage := tracker.Roll(start.Add(13 * time.Minute))
+	if len(usage) != 2 {
+		t.Fatalf("Expected 2 keys, but got %d", len(usage))
+	}
+	found := usage[1]
+	if found.Key != carrier || found.Peak != 3 || found.Current != 2 || !found.Peaked.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Unexpected usage %+v", found)
+	}
+	if !found.Start.Equal(start.Truncate(15*time.Minute)) || !found.End.Equal(found.Start.Add(15*time.Minute)) {
+		t.Errorf("Unexpected interval %v to %v", found.Start, found.End)
+	}
+
+	// carried channels set the peak of the next interval
+	tracker.Release(carrier, start.Add(14*time.Minute))
+	tracker.Release(carrier, start.Add(15*time.Minute))
+	usage = tracker.Roll(start.Add(28 * time.Minute))
+	if len(usage) != 2 || usage[1].Peak != 2 || usage[1].Current != 0 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+	if tracker.Current(carrier) != 0 || tracker.Current(node) != 2 {
+		t.Errorf("Unexpected current %d and %d", tracker.Current(carrier), tracker.Current(node))
+	}
+}
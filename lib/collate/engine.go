@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package collate
+
+import (
+	"time"
+)
+
+// Evidence is what the initial invite of a leg tells us about it
+type Evidence struct {
+	Key       string // identifies the leg to the caller
+	CallID    string
+	Created   time.Time
+	Original  string   // call id of prior leg from a b2bua header
+	SessionID string   // rfc 7989 uuid
+	Caller    string   // asserted or from user
+	Called    string   // request uri user
+	Diverted  []string // diversion and history-info users
+	Media     string   // sdp audio address and port
+}
+
+// Rules set which evidence is used and how much each is worth
+type Rules struct {
+	Window    time.Duration // invites closer than this are related
+	Threshold int           // minimum score to collate
+
+	Original  int
+	SessionID int
+	Media     int
+	Diverted  int
+	Caller    int
+	Proximity int
+}
+
+// Engine matches legs a b2bua sent out to legs that arrived at it
+type Engine struct {
+	Rules   Rules
+	arrived map[string]*Evidence
+}
+
+func DefaultRules() Rules {
+	return Rules{
+		Window:    5 * time.Second,
+		Threshold: 50,
+		Original:  100,
+		SessionID: 100,
+		Media:     60,
+		Diverted:  40,
+		Caller:    30,
+		Proximity: 20,
+	}
+}
+
+func NewEngine(rules Rules) *Engine {
+	return &Engine{
+		Rules:   rules,
+		arrived: make(map[string]*Evidence),
+	}
+}
+
+// Arrived adds a leg that came in to the b2bua as a candidate
+func (engine *Engine) Arrived(evidence *Evidence) {
+	engine.arrived[evidence.Key] = evidence
+}
+
+// Release removes a candidate when its leg ends
+func (engine *Engine) Release(key string) {
+	delete(engine.arrived, key)
+}
+
+func (engine *Engine) Pending() int {
+	return len(engine.arrived)
+}
+
+// Match finds the best arrived leg for a leg the b2bua sent out, which
+// needs strong evidence as a shared caller id and timing are common
+func (engine *Engine) Match(evidence *Evidence) (*Evidence, int) {
+	var best *Evidence
+	high := 0
+	for _, candidate := range engine.arrived {
+		if candidate.CallID == evidence.CallID {
+			continue // same call id is not a b2bua pair
+		}
+		if !engine.Strong(candidate, evidence) {
+			continue
+		}
+		score := engine.Score(candidate, evidence)
+		if score == 0 || score < engine.Rules.Threshold || score < high {
+			continue
+		}
+		if score == high && best != nil && gap(best, evidence) <= gap(candidate, evidence) {
+			continue
+		}
+		best = candidate
+		high = score
+	}
+	return best, high
+}
+
+// Score weighs how likely an outgoing leg continues an arrived leg
+func (engine *Engine) Score(arrived, sent *Evidence) int {
+	rules := &engine.Rules
+	score := 0
+	if len(sent.Original) > 0 && sent.Original == arrived.CallID {
+		score += rules.Original
+	}
+	if len(sent.SessionID) > 0 && sent.SessionID == arrived.SessionID {
+		score += rules.SessionID
+	}
+	if len(sent.Media) > 0 && sent.Media == arrived.Media {
+		score += rules.Media
+	}
+	if len(arrived.Called) > 0 {
+		for _, user := range sent.Diverted {
+			if user == arrived.Called {
+				score += rules.Diverted
+				break
+			}
+		}
+	}
+	if len(sent.Caller) > 0 && sent.Caller == arrived.Caller {
+		score += rules.Caller
+	}
+	if rules.Window > 0 && !sent.Created.Before(arrived.Created) && gap(arrived, sent) <= rules.Window {
+		score += rules.Proximity
+	}
+	return score
+}
+
+// Strong tests for evidence that alone identifies a leg
+func (engine *Engine) Strong(arrived, sent *Evidence) bool {
+	rules := &engine.Rules
+	switch {
+	case rules.Original > 0 && len(sent.Original) > 0 && sent.Original == arrived.CallID:
+		return true
+	case rules.SessionID > 0 && len(sent.SessionID) > 0 && sent.SessionID == arrived.SessionID:
+		return true
+	case rules.Media > 0 && len(sent.Media) > 0 && sent.Media == arrived.Media:
+		return true
+	}
+	return false
+}
+
+func gap(arrived, sent *Evidence) time.Duration {
+	delta := sent.Created.Sub(arrived.Created)
+	if delta < 0 {
+		return -delta
+	}
+	return delta
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package collate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngineMatch(t *testing.T) {
+	start := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewEngine(DefaultRules())
+	engine.Arrived(&Evidence{Key: "a1", CallID: "a1", Created: start, Caller: "1000", Called: "2000", Media: "10.0.0.5/4000"})
+	engine.Arrived(&Evidence{Key: "b1", CallID: "b1", Created: start.Add(time.Second), Caller: "1001", Called: "2001"})
+
+	// media, caller, and proximity
+	found, score := engine.Match(&Evidence{CallID: "a2", Created: start.Add(2 * time.Second), Caller: "1000", Media: "10.0.0.5/4000"})
+	if found == nil || found.CallID != "a1" || score != 110 {
+		t.Fatalf("Expected a1 with 110, but got %v with %d", found, score)
+	}
+
+	// a shared caller id in the window alone is not the same call
+	found, score = engine.Match(&Evidence{CallID: "a4", Created: start.Add(2 * time.Second), Caller: "1000"})
+	if found != nil {
+		t.Fatalf("Expected same caller calls kept apart, but got %v with %d", found, score)
+	}
+
+	// b2bua header wins without any timing
+	found, _ = engine.Match(&Evidence{CallID: "b2", Created: start.Add(time.Hour), Original: "b1"})
+	if found == nil || found.CallID != "b1" {
+		t.Fatalf("Expected b1, but got %v", found)
+	}
+
+	// diversion of called number with no caller match
+	found, _ = engine.Match(&Evidence{CallID: "c2", Created: start.Add(time.Minute), Diverted: []string{"2001"}, Caller: "9999"})
+	if found != nil {
+		t.Fatalf("Expected no match below threshold, but got %v", found)
+	}
+
+	engine.Release("a1")
+	found, _ = engine.Match(&Evidence{CallID: "a3", Created: start.Add(2 * time.Second), Caller: "1000", Media: "10.0.0.5/4000"})
+	if found != nil {
+		t.Fatalf("Expected released candidate to not match, but got %v", found)
+	}
+}
+
+func TestEngineRules(t *testing.T) {
+	start := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	rules := DefaultRules()
+	engine := NewEngine(rules)
+	engine.Arrived(&Evidence{Key: "early", CallID: "a1", Created: start, SessionID: "s1"})
+	engine.Arrived(&Evidence{Key: "late", CallID: "b1", Created: start.Add(3 * time.Second), SessionID: "s1"})
+
+	// equal scores go to the candidate closest in time
+	found, score := engine.Match(&Evidence{CallID: "c1", Created: start.Add(4 * time.Second), SessionID: "s1"})
+	if found == nil || found.Key != "late" || score != 120 {
+		t.Fatalf("Expected late with 120, but got %v with %d", found, score)
+	}
+
+	// a score at the threshold matches, below it does not
+	engine.Rules.Threshold = 121
+	if found, _ = engine.Match(&Evidence{CallID: "c2", Created: start.Add(4 * time.Second), SessionID: "s1"}); found != nil {
+		t.Fatalf("Expected no match over threshold, but got %v", found)
+	}
+	engine.Rules.Threshold = 120
+	if found, _ = engine.Match(&Evidence{CallID: "c2", Created: start.Add(4 * time.Second), SessionID: "s1"}); found == nil {
+		t.Fatal("Expected match at threshold")
+	}
+	engine.Rules.Threshold = rules.Threshold
+
+	// a zero weight turns session evidence off, leaving nothing strong
+	engine.Rules.SessionID = 0
+	found, score = engine.Match(&Evidence{CallID: "c3", Created: start.Add(4 * time.Second), SessionID: "s1"})
+	if found != nil {
+		t.Fatalf("Expected no match without session weight, but got %v with %d", found, score)
+	}
+	engine.Rules.SessionID = rules.SessionID
+
+	// released candidates are no longer matched
+	engine.Release("late")
+	found, _ = engine.Match(&Evidence{CallID: "c4", Created: start.Add(4 * time.Second), SessionID: "s1"})
+	if found == nil || found.Key != "early" || engine.Pending() != 1 {
+		t.Fatalf("Expected early after release, but got %v", found)
+	}
+}
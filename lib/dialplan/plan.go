@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package dialplan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Class string
+
+// Plan holds numbering rules of a country or of a trunk
+type Plan struct {
+	Country       string   `ini:"country"`       // country code, 1 for nanp
+	Trunk         string   `ini:"trunk"`         // national trunk prefix
+	International string   `ini:"international"` // international access prefix
+	Area          string   `ini:"area"`          // home area code for local calls
+	National      int      `ini:"national"`      // digits of a national significant number
+	Local         int      `ini:"local"`         // digits of a number dialed in the area
+	Extension     int      `ini:"extension"`     // most digits of an internal extension
+	Strip         []string `ini:"strip" delim:","`
+	Prepend       []string `ini:"prepend" delim:","` // pattern=prefix
+	Emergency     []string `ini:"emergency" delim:","`
+	TollFree      []string `ini:"tollfree" delim:","` // national prefixes
+	Premium       []string `ini:"premium" delim:","`  // national prefixes
+
+	strip   []*regexp.Regexp
+	prepend []rewrite
+}
+
+// Number is a normalized number and what kind of call it is
+type Number struct {
+	Original string
+	E164     string // +cc form, or digits as dialed for emergency and internal
+	Class    Class
+}
+
+type rewrite struct {
+	pattern *regexp.Regexp
+	prefix  string
+}
+
+const (
+	Unknown       Class = "unknown"
+	Internal      Class = "internal"
+	Emergency     Class = "emergency"
+	Local         Class = "local"
+	National      Class = "national"
+	International Class = "international"
+	TollFree      Class = "tollfree"
+	Premium       Class = "premium"
+)
+
+// Compile prepares strip and prepend patterns of a plan
+func (plan *Plan) Compile() error {
+	plan.strip = nil
+	plan.prepend = nil
+	for _, pattern := range plan.Strip {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		expr, err := regexp.Compile("^(?:" + pattern + ")")
+		if err != nil {
+			return fmt.Errorf("strip %s: %v", pattern, err)
+		}
+		plan.strip = append(plan.strip, expr)
+	}
+	for _, rule := range plan.Prepend {
+		pattern, prefix, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			return fmt.Errorf("prepend %s: missing prefix", rule)
+		}
+		expr, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("prepend %s: %v", rule, err)
+		}
+		plan.prepend = append(plan.prepend, rewrite{pattern: expr, prefix: prefix})
+	}
+	return nil
+}
+
+// Normalize converts a dialed or presented number to e164 and classifies it
+func (plan *Plan) Normalize(number string) Number {
+	result := Number{Original: number, Class: Unknown}
+	for _, expr := range plan.strip {
+		if loc := expr.FindStringIndex(number); loc != nil {
+			number = number[loc[1]:]
+			break
+		}
+	}
+	for _, rule := range plan.prepend {
+		if rule.pattern.MatchString(number) {
+			number = rule.prefix + number
+			break
+		}
+	}
+	if len(number) == 0 || !digits(strings.TrimPrefix(number, "+")) {
+		return result
+	}
+
+	if contains(plan.Emergency, number) {
+		result.E164 = number
+		result.Class = Emergency
+		return result
+	}
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		result.E164 = number
+	case len(plan.International) > 0 && strings.HasPrefix(number, plan.International):
+		result.E164 = "+" + number[len(plan.International):]
+	case plan.Extension > 0 && len(number) <= plan.Extension:
+		result.E164 = number
+		result.Class = Internal
+		return result
+	case len(plan.Trunk) > 0 && strings.HasPrefix(number, plan.Trunk) && (plan.National == 0 || len(number)-len(plan.Trunk) == plan.National):
+		result.E164 = "+" + plan.Country + number[len(plan.Trunk):]
+	case plan.National > 0 && len(number) == plan.National:
+		result.E164 = "+" + plan.Country + number
+	case plan.Local > 0 && len(number) == plan.Local && len(plan.Area) > 0:
+		result.E164 = "+" + plan.Country + plan.Area + number
+	default:
+		return result
+	}
+
+	result.Class = plan.Classify(result.E164)
+	return result
+}
+
+// Classify an e164 number relative to the home country of the plan
+func (plan *Plan) Classify(e164 string) Class {
+	if !strings.HasPrefix(e164, "+"+plan.Country) || len(plan.Country) == 0 {
+		return International
+	}
+	national := e164[len(plan.Country)+1:]
+	switch {
+	case prefixed(plan.TollFree, national):
+		return TollFree
+	case prefixed(plan.Premium, national):
+		return Premium
+	case len(plan.Area) > 0 && strings.HasPrefix(national, plan.Area):
+		return Local
+	}
+	return National
+}
+
+func digits(number string) bool {
+	for _, b := range number {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return len(number) > 0
+}
+
+func contains(list []string, item string) bool {
+	for _, entry := range list {
+		if strings.TrimSpace(entry) == item {
+			return true
+		}
+	}
+	return false
+}
+
+func prefixed(list []string, number string) bool {
+	for _, prefix := range list {
+		prefix = strings.TrimSpace(prefix)
+		if len(prefix) > 0 && strings.HasPrefix(number, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithContext applies a global number phone-context to a local number
+func WithContext(number, context string) string {
+	if !strings.HasPrefix(context, "+") || strings.HasPrefix(number, "+") {
+		return number
+	}
+	prefix := make([]byte, 0, len(context))
+	for _, b := range []byte(context) {
+		if b == '+' || (b >= '0' && b <= '9') {
+			prefix = append(prefix, b)
+		}
+	}
+	return string(prefix) + number
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package dialplan
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	plan := &Plan{
+		Country:       "1",
+		Trunk:         "1",
+		International: "011",
+		Area:          "212",
+		National:      10,
+		Local:         7,
+		Extension:     4,
+		Strip:         []string{`\*67`, `99#`},
+		Prepend:       []string{`[2-9]\d{2}[2-9]\d{6}=1`},
+		Emergency:     []string{"911"},
+		TollFree:      []string{"800", "888"},
+		Premium:       []string{"900"},
+	}
+	if err := plan.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		number string
+		e164   string
+		class  Class
+	}{
+		{"+12125551234", "+12125551234", Local},
+		{"12125551234", "+12125551234", Local},
+		{"5551234", "+12125551234", Local},
+		{"3105551234", "+13105551234", National},
+		{"*673105551234", "+13105551234", National},
+		{"99#18005551234", "+18005551234", TollFree},
+		{"19005551234", "+19005551234", Premium},
+		{"011442071234567", "+442071234567", International},
+		{"911", "911", Emergency},
+		{"2001", "2001", Internal},
+		{"alice", "", Unknown},
+	}
+	for _, test := range tests {
+		result := plan.Normalize(test.number)
+		if result.E164 != test.e164 || result.Class != test.class {
+			t.Errorf("%s: expected %s %s, but got %s %s", test.number, test.e164, test.class, result.E164, result.Class)
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	if number := WithContext("5551234", "+1-212"); number != "+12125551234" {
+		t.Errorf("Expected +12125551234, but got %s", number)
+	}
+	if number := WithContext("5551234", "example.com"); number != "5551234" {
+		t.Errorf("Expected 5551234, but got %s", number)
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"spycraft/lib/cdr"
+	"spycraft/lib/service"
+)
+
+// Event is a leg state change pushed to stream subscribers
+type Event struct {
+	ID         uint64           `json:"id"`
+	Type       string           `json:"type"`
+	Time       time.Time        `json:"time"`
+	Node       string           `json:"node"`
+	Leg        string           `json:"leg"`
+	Collated   string           `json:"collated"`
+	CallID     string           `json:"callid"`
+	Trunk      string           `json:"trunk,omitempty"`
+	Incoming   bool             `json:"incoming"`
+	Caller     cdr.Party        `json:"caller"`
+	Called     cdr.Party        `json:"called"`
+	Status     int              `json:"status,omitempty"`
+	Duration   service.Duration `json:"duration,omitempty"`
+	Transfer   *cdr.Transfer    `json:"transfer,omitempty"`
+	Disconnect *cdr.Disconnect  `json:"disconnect,omitempty"`
+}
+
+// Filter selects events, with empty fields matching anything
+type Filter struct {
+	Trunk     string
+	Number    string // caller or called number or e164
+	Direction string // incoming or outgoing
+}
+
+// Subscriber receives matching events until closed, which happens
+// if it falls too far behind and should resume from its last id
+type Subscriber struct {
+	Events chan *Event
+	filter Filter
+}
+
+// Broker numbers events and keeps recent history for resuming
+type Broker struct {
+	lock        sync.Mutex
+	next        uint64
+	history     []*Event
+	head        int
+	count       int
+	subscribers map[*Subscriber]struct{}
+}
+
+const (
+	Created     = "created"
+	Ringing     = "ringing"
+	Answered    = "answered"
+	Held        = "held"
+	Resumed     = "resumed"
+	Transferred = "transferred"
+	Ended       = "ended"
+)
+
+const buffered = 256
+
+func NewBroker(size int) *Broker {
+	if size < 1 {
+		size = 1
+	}
+	return &Broker{
+		history:     make([]*Event, size),
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish numbers an event and sends it to matching subscribers
+func (broker *Broker) Publish(event *Event) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	broker.next++
+	event.ID = broker.next
+	broker.history[(broker.head+broker.count)%len(broker.history)] = event
+	if broker.count < len(broker.history) {
+		broker.count++
+	} else {
+		broker.head = (broker.head + 1) % len(broker.history)
+	}
+
+	for subscriber := range broker.subscribers {
+		if !subscriber.filter.Match(event) {
+			continue
+		}
+		select {
+		case subscriber.Events <- event:
+		default:
+			delete(broker.subscribers, subscriber)
+			close(subscriber.Events) // lagging, client resumes from cursor
+		}
+	}
+}
+
+// Subscribe returns matching history after a cursor and a subscriber
+// for the events that follow it
+func (broker *Broker) Subscribe(filter Filter, since uint64) (*Subscriber, []*Event) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	var backlog []*Event
+	if since > 0 {
+		for pos := 0; pos < broker.count; pos++ {
+			event := broker.history[(broker.head+pos)%len(broker.history)]
+			if event.ID > since && filter.Match(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+	subscriber := &Subscriber{
+		Events: make(chan *Event, buffered),
+		filter: filter,
+	}
+	broker.subscribers[subscriber] = struct{}{}
+	return subscriber, backlog
+}
+
+func (broker *Broker) Unsubscribe(subscriber *Subscriber) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	if _, found := broker.subscribers[subscriber]; found {
+		delete(broker.subscribers, subscriber)
+		close(subscriber.Events)
+	}
+}
+
+// Last returns the id of the newest event
+func (broker *Broker) Last() uint64 {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	return broker.next
+}
+
+func (filter *Filter) Match(event *Event) bool {
+	if len(filter.Trunk) > 0 && !strings.EqualFold(filter.Trunk, event.Trunk) {
+		return false
+	}
+	switch strings.ToLower(filter.Direction) {
+	case "incoming":
+		if !event.Incoming {
+			return false
+		}
+	case "outgoing":
+		if event.Incoming {
+			return false
+		}
+	}
+	if len(filter.Number) > 0 && !matchNumber(filter.Number, &event.Caller) && !matchNumber(filter.Number, &event.Called) {
+		return false
+	}
+	return true
+}
+
+func matchNumber(number string, party *cdr.Party) bool {
+	return strings.Contains(party.Number, number) || strings.Contains(party.E164, number)
+}
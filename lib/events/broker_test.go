@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package events
+
+import (
+	"testing"
+
+	"spycraft/lib/cdr"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker(4)
+	for _, trunk := range []string{"carrier", "pbx", "carrier", "carrier", "pbx"} {
+		broker.Publish(&Event{Type: Created, Trunk: trunk})
+	}
+	if broker.Last() != 5 {
+		t.Fatalf("Expected last id 5, but got %d", broker.Last())
+	}
+
+	// resume after 2 finds 3 and 4 of carrier, 1 has left history
+	subscriber, backlog := broker.Subscribe(Filter{Trunk: "carrier"}, 2)
+	if len(backlog) != 2 || backlog[0].ID != 3 || backlog[1].ID != 4 {
+		t.Fatalf("Unexpected backlog %+v", backlog)
+	}
+	broker.Publish(&Event{Type: Ended, Trunk: "pbx"})
+	broker.Publish(&Event{Type: Ended, Trunk: "carrier", Incoming: true})
+	if event := <-subscriber.Events; event.ID != 7 {
+		t.Errorf("Expected event 7, but got %d", event.ID)
+	}
+	broker.Unsubscribe(subscriber)
+	if _, open := <-subscriber.Events; open {
+		t.Errorf("Expected closed subscriber")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	event := &Event{Incoming: true, Called: cdr.Party{Number: "2125551234", E164: "+12125551234"}}
+	tests := map[Filter]bool{
+		{}:                      true,
+		{Direction: "incoming"}: true,
+		{Direction: "outgoing"}: false,
+		{Number: "+1212"}:       true,
+		{Number: "555"}:         true,
+		{Number: "999"}:         false,
+		{Trunk: "carrier", Direction: "incoming"}: false,
+	}
+	for filter, expected := range tests {
+		if filter.Match(event) != expected {
+			t.Errorf("Expected %v for %+v", expected, filter)
+		}
+	}
+}
+
+func TestLagging(t *testing.T) {
+	broker := NewBroker(1)
+	subscriber, _ := broker.Subscribe(Filter{}, 0)
+	for count := 0; count <= buffered; count++ {
+		broker.Publish(&Event{Type: Created})
+	}
+	received := 0
+	for range subscriber.Events {
+		received++
+	}
+	if received != buffered {
+		t.Errorf("Expected %d events before close, but got %d", buffered, received)
+	}
+}
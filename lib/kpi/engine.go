@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package kpi
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attempt is one completed leg as counted by the engine
+type Attempt struct {
+	Answered  bool
+	Effective bool // answered or ended by the user rather than the network
+	Alerted   bool
+	Duration  time.Duration
+	PostDial  time.Duration
+}
+
+// Summary is the kpi of one key over one sliding window
+type Summary struct {
+	Window    string  `json:"window"`
+	Seizures  int64   `json:"seizures"`
+	Answered  int64   `json:"answered"`
+	Effective int64   `json:"effective"`
+	ASR       float64 `json:"asr"`    // answer seizure ratio percent
+	ACD       float64 `json:"acd"`    // average call duration seconds
+	NER       float64 `json:"ner"`    // network effectiveness ratio percent
+	PDD       float64 `json:"pdd_ms"` // average post dial delay
+	SCR       float64 `json:"scr"`    // short call ratio percent of answered
+}
+
+type counts struct {
+	seizures  int64
+	answered  int64
+	effective int64
+	alerted   int64
+	short     int64
+	duration  time.Duration
+	postdial  time.Duration
+}
+
+type bucket struct {
+	slot int64
+	counts
+}
+
+type window struct {
+	span    time.Duration
+	width   time.Duration
+	buckets []bucket
+}
+
+type series struct {
+	windows []*window
+}
+
+// Engine keeps sliding window counts per key, such as node, trunk, or
+// prefix, with time taken from the attempts so pcap scans work too
+type Engine struct {
+	sync.Mutex
+	Short  time.Duration // answered calls shorter than this are short
+	spans  []time.Duration
+	series map[string]*series
+	latest time.Time
+}
+
+const resolution = 60 // buckets per window
+
+var DefaultWindows = []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}
+
+func NewEngine(short time.Duration, spans ...time.Duration) *Engine {
+	if len(spans) == 0 {
+		spans = DefaultWindows
+	}
+	return &Engine{
+		Short:  short,
+		spans:  spans,
+		series: make(map[string]*series),
+	}
+}
+
+// Add counts an attempt that finished at a time under each key
+func (engine *Engine) Add(when time.Time, attempt Attempt, keys ...string) {
+	sample := counts{seizures: 1}
+	if attempt.Answered {
+		sample.answered = 1
+		sample.effective = 1
+		sample.duration = attempt.Duration
+		if attempt.Duration < engine.Short {
+			sample.short = 1
+		}
+	} else if attempt.Effective {
+		sample.effective = 1
+	}
+	if attempt.Alerted {
+		sample.alerted = 1
+		sample.postdial = attempt.PostDial
+	}
+
+	engine.Lock()
+	defer engine.Unlock()
+	if when.After(engine.latest) {
+		engine.latest = when
+	}
+	for _, key := range keys {
+		found := engine.series[key]
+		if found == nil {
+			found = engine.newSeries()
+			engine.series[key] = found
+		}
+		for _, window := range found.windows {
+			window.add(when, sample)
+		}
+	}
+}
+
+// Advance moves the engine clock when there are no attempts to do so
+func (engine *Engine) Advance(now time.Time) {
+	engine.Lock()
+	defer engine.Unlock()
+	if now.After(engine.latest) {
+		engine.latest = now
+	}
+}
+
+// Summary of a key for each window at the latest time seen
+func (engine *Engine) Summary(key string) []Summary {
+	engine.Lock()
+	defer engine.Unlock()
+	found := engine.series[key]
+	if found == nil {
+		return nil
+	}
+	return found.summary(engine.latest)
+}
+
+// Snapshot summarizes all keys
+func (engine *Engine) Snapshot() map[string][]Summary {
+	engine.Lock()
+	defer engine.Unlock()
+	snapshot := make(map[string][]Summary, len(engine.series))
+	for key, found := range engine.series {
+		snapshot[key] = found.summary(engine.latest)
+	}
+	return snapshot
+}
+
+// Keys returns sorted keys, optionally of a kind such as "trunk/"
+func (engine *Engine) Keys(prefix string) []string {
+	engine.Lock()
+	defer engine.Unlock()
+	keys := make([]string, 0, len(engine.series))
+	for key := range engine.series {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Prune drops keys with nothing left in their longest window
+func (engine *Engine) Prune() int {
+	engine.Lock()
+	defer engine.Unlock()
+	count := 0
+	for key, found := range engine.series {
+		last := found.windows[len(found.windows)-1]
+		if last.total(engine.latest).seizures == 0 {
+			delete(engine.series, key)
+			count++
+		}
+	}
+	return count
+}
+
+func (engine *Engine) newSeries() *series {
+	found := &series{}
+	for _, span := range engine.spans {
+		width := span / resolution
+		if width < time.Second {
+			width = time.Second
+		}
+		found.windows = append(found.windows, &window{
+			span:    span,
+			width:   width,
+			buckets: make([]bucket, int(span/width)),
+		})
+	}
+	return found
+}
+
+func (found *series) summary(now time.Time) []Summary {
+	summaries := make([]Summary, 0, len(found.windows))
+	for _, window := range found.windows {
+		total := window.total(now)
+		summary := Summary{
+			Window:    Label(window.span),
+			Seizures:  total.seizures,
+			Answered:  total.answered,
+			Effective: total.effective,
+			ASR:       percent(total.answered, total.seizures),
+			NER:       percent(total.effective, total.seizures),
+			SCR:       percent(total.short, total.answered),
+		}
+		if total.answered > 0 {
+			summary.ACD = round(total.duration.Seconds() / float64(total.answered))
+		}
+		if total.alerted > 0 {
+			summary.PDD = round(float64(total.postdial.Milliseconds()) / float64(total.alerted))
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func (window *window) add(when time.Time, sample counts) {
+	slot := when.UnixNano() / int64(window.width)
+	entry := &window.buckets[slot%int64(len(window.buckets))]
+	if entry.slot != slot {
+		entry.slot = slot
+		entry.counts = counts{}
+	}
+	entry.seizures += sample.seizures
+	entry.answered += sample.answered
+	entry.effective += sample.effective
+	entry.alerted += sample.alerted
+	entry.short += sample.short
+	entry.duration += sample.duration
+	entry.postdial += sample.postdial
+}
+
+func (window *window) total(now time.Time) counts {
+	var total counts
+	current := now.UnixNano() / int64(window.width)
+	oldest := current - int64(len(window.buckets))
+	for _, entry := range window.buckets {
+		if entry.slot <= oldest || entry.slot > current {
+			continue
+		}
+		total.seizures += entry.seizures
+		total.answered += entry.answered
+		total.effective += entry.effective
+		total.alerted += entry.alerted
+		total.short += entry.short
+		total.duration += entry.duration
+		total.postdial += entry.postdial
+	}
+	return total
+}
+
+// Label formats a window span as 5m, 1h, or 24h
+func Label(span time.Duration) string {
+	switch {
+	case span%time.Hour == 0:
+		return strings.TrimSuffix(span.String(), "0m0s")
+	case span%time.Minute == 0:
+		return strings.TrimSuffix(span.String(), "0s")
+	}
+	return span.String()
+}
+
+func percent(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return round(float64(part) * 100 / float64(whole))
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package kpi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngine(t *testing.T) {
+	engine := NewEngine(6 * time.Second)
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// answered long, answered short, busy, network failure
+	engine.Add(start, Attempt{Answered: true, Alerted: true, Duration: 90 * time.Second, PostDial: 2 * time.Second}, "node", "trunk/carrier")
+	engine.Add(start.Add(time.Minute), Attempt{Answered: true, Alerted: true, Duration: 4 * time.Second, PostDial: 4 * time.Second}, "node", "trunk/carrier")
+	engine.Add(start.Add(2*time.Minute), Attempt{Effective: true}, "node")
+	engine.Add(start.Add(3*time.Minute), Attempt{}, "node", "trunk/carrier")
+
+	summary := engine.Summary("node")
+	if len(summary) != 3 || summary[0].Window != "5m" || summary[2].Window != "24h" {
+		t.Fatalf("Unexpected windows %+v", summary)
+	}
+	five := summary[0]
+	if five.Seizures != 4 || five.ASR != 50 || five.NER != 75 || five.ACD != 47 || five.PDD != 3000 || five.SCR != 50 {
+		t.Errorf("Unexpected summary %+v", five)
+	}
+	if carrier := engine.Summary("trunk/carrier")[0]; carrier.Seizures != 3 || carrier.ASR != 66.67 {
+		t.Errorf("Unexpected carrier summary %+v", carrier)
+	}
+
+	// the first attempt slides out of the 5 minute window only
+	engine.Advance(start.Add(5*time.Minute + 30*time.Second))
+	summary = engine.Summary("node")
+	if summary[0].Seizures != 3 || summary[1].Seizures != 4 {
+		t.Errorf("Expected 3 and 4 seizures, but got %d and %d", summary[0].Seizures, summary[1].Seizures)
+	}
+
+	engine.Advance(start.Add(25 * time.Hour))
+	if pruned := engine.Prune(); pruned != 2 {
+		t.Errorf("Expected 2 keys pruned, but got %d", pruned)
+	}
+	if keys := engine.Keys(""); len(keys) != 0 {
+		t.Errorf("Expected no keys, but got %v", keys)
+	}
+}
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector writes the samples of one metric family
+type Collector interface {
+	Name() string
+	Help() string
+	Kind() string // counter or gauge
+	Collect(emit func(value float64, labels ...string))
+}
+
+// Registry renders collectors in prometheus text exposition format
+type Registry struct {
+	sync.Mutex
+	collectors []Collector
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// Counter is a monotonic value without labels
+type Counter struct {
+	family
+	value atomic.Uint64
+}
+
+// CounterVec is a set of counters keyed by label values
+type CounterVec struct {
+	family
+	sync.Mutex
+	counters map[string]*Counter
+	values   map[string][]string
+}
+
+// GaugeVec is a set of gauges replaced together
+type GaugeVec struct {
+	family
+	sync.Mutex
+	values map[string]float64
+	sets   map[string][]string
+}
+
+// Func reads a gauge or counter value when scraped
+type Func struct {
+	family
+	read func() float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (registry *Registry) Register(collector Collector) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.collectors = append(registry.collectors, collector)
+}
+
+func (registry *Registry) Counter(name, help string) *Counter {
+	counter := &Counter{family: family{name: name, help: help, kind: "counter"}}
+	registry.Register(counter)
+	return counter
+}
+
+func (registry *Registry) CounterVec(name, help string, labels ...string) *CounterVec {
+	vec := &CounterVec{
+		family:   family{name: name, help: help, kind: "counter", labels: labels},
+		counters: make(map[string]*Counter),
+		values:   make(map[string][]string),
+	}
+	registry.Register(vec)
+	return vec
+}
+
+func (registry *Registry) GaugeVec(name, help string, labels ...string) *GaugeVec {
+	vec := &GaugeVec{
+		family: family{name: name, help: help, kind: "gauge", labels: labels},
+		values: make(map[string]float64),
+		sets:   make(map[string][]string),
+	}
+	registry.Register(vec)
+	return vec
+}
+
+func (registry *Registry) GaugeFunc(name, help string, read func() float64) *Func {
+	gauge := &Func{family: family{name: name, help: help, kind: "gauge"}, read: read}
+	registry.Register(gauge)
+	return gauge
+}
+
+func (registry *Registry) CounterFunc(name, help string, read func() float64) *Func {
+	counter := &Func{family: family{name: name, help: help, kind: "counter"}, read: read}
+	registry.Register(counter)
+	return counter
+}
+
+// Write renders all families sorted by name
+func (registry *Registry) Write(output io.Writer) error {
+	registry.Lock()
+	collectors := append([]Collector(nil), registry.collectors...)
+	registry.Unlock()
+	sort.SliceStable(collectors, func(i, j int) bool {
+		return collectors[i].Name() < collectors[j].Name()
+	})
+
+	writer := bufio.NewWriter(output)
+	for _, collector := range collectors {
+		fmt.Fprintf(writer, "# HELP %s %s\n", collector.Name(), escapeHelp(collector.Help()))
+		fmt.Fprintf(writer, "# TYPE %s %s\n", collector.Name(), collector.Kind())
+		names := labelNames(collector)
+		collector.Collect(func(value float64, labels ...string) {
+			writer.WriteString(collector.Name())
+			if len(labels) > 0 {
+				writer.WriteByte('{')
+				for pos, label := range labels {
+					if pos > 0 {
+						writer.WriteByte(',')
+					}
+					fmt.Fprintf(writer, "%s=\"%s\"", names[pos], escapeLabel(label))
+				}
+				writer.WriteByte('}')
+			}
+			writer.WriteByte(' ')
+			writer.WriteString(formatValue(value))
+			writer.WriteByte('\n')
+		})
+	}
+	return writer.Flush()
+}
+
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	registry.Write(w)
+}
+
+func (family *family) Name() string {
+	return family.name
+}
+
+func (family *family) Help() string {
+	return family.help
+}
+
+func (family *family) Kind() string {
+	return family.kind
+}
+
+func (counter *Counter) Inc() {
+	counter.value.Add(1)
+}
+
+func (counter *Counter) Add(count uint64) {
+	counter.value.Add(count)
+}
+
+func (counter *Counter) Value() uint64 {
+	return counter.value.Load()
+}
+
+func (counter *Counter) Collect(emit func(float64, ...string)) {
+	emit(float64(counter.value.Load()))
+}
+
+// With finds or creates the counter of a set of label values
+func (vec *CounterVec) With(labels ...string) *Counter {
+	key := strings.Join(labels, "\xff")
+	vec.Lock()
+	defer vec.Unlock()
+	counter := vec.counters[key]
+	if counter == nil {
+		counter = &Counter{}
+		vec.counters[key] = counter
+		vec.values[key] = append([]string(nil), labels...)
+	}
+	return counter
+}
+
+func (vec *CounterVec) Collect(emit func(float64, ...string)) {
+	vec.Lock()
+	defer vec.Unlock()
+	for _, key := range sortedKeys(vec.counters) {
+		emit(float64(vec.counters[key].Value()), vec.values[key]...)
+	}
+}
+
+func (vec *GaugeVec) Set(value float64, labels ...string) {
+	key := strings.Join(labels, "\xff")
+	vec.Lock()
+	defer vec.Unlock()
+	vec.values[key] = value
+	vec.sets[key] = append([]string(nil), labels...)
+}
+
+// Replace swaps in a complete set of gauges keyed by a single label
+func (vec *GaugeVec) Replace(values map[string]int) {
+	vec.Lock()
+	defer vec.Unlock()
+	vec.values = make(map[string]float64, len(values))
+	vec.sets = make(map[string][]string, len(values))
+	for label, value := range values {
+		vec.values[label] = float64(value)
+		vec.sets[label] = []string{label}
+	}
+}
+
+func (vec *GaugeVec) Collect(emit func(float64, ...string)) {
+	vec.Lock()
+	defer vec.Unlock()
+	for _, key := range sortedKeys(vec.values) {
+		emit(vec.values[key], vec.sets[key]...)
+	}
+}
+
+func (metric *Func) Collect(emit func(float64, ...string)) {
+	emit(metric.read())
+}
+
+func labelNames(collector Collector) []string {
+	switch metric := collector.(type) {
+	case *CounterVec:
+		return metric.labels
+	case *GaugeVec:
+		return metric.labels
+	}
+	return nil
+}
+
+func sortedKeys[V any](items map[string]V) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabel(label string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(label)
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.CounterVec("sip_requests_total", "SIP requests seen", "method")
+	errors := registry.Counter("parse_errors_total", "Unparsable messages")
+	calls := registry.GaugeVec("trunk_calls", "Connected calls", "trunk")
+	registry.GaugeFunc("active_legs", "Legs tracked", func() float64 { return 3 })
+
+	requests.With("INVITE").Add(2)
+	requests.With("BYE").Inc()
+	errors.Inc()
+	calls.Replace(map[string]int{"carrier": 4, `say "hi"`: 1})
+
+	var out strings.Builder
+	if err := registry.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP active_legs Legs tracked
+# TYPE active_legs gauge
+active_legs 3
+# HELP parse_errors_total Unparsable messages
+# TYPE parse_errors_total counter
+parse_errors_total 1
+# HELP sip_requests_total SIP requests seen
+# TYPE sip_requests_total counter
+sip_requests_total{method="BYE"} 1
+sip_requests_total{method="INVITE"} 2
+# HELP trunk_calls Connected calls
+# TYPE trunk_calls gauge
+trunk_calls{trunk="carrier"} 4
+trunk_calls{trunk="say \"hi\""} 1
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected exposition:\n%s", out.String())
+	}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rating
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is one destination prefix of a rate deck
+type Rate struct {
+	Prefix      string
+	PerMinute   float64
+	Connection  float64
+	Initial     int // seconds of first billing increment
+	Increment   int // seconds of each following increment
+	Description string
+}
+
+// Deck is a set of rates matched by longest prefix
+type Deck struct {
+	Name      string
+	Currency  string
+	Precision int // decimal places costs are rounded to
+	rates     map[string]*Rate
+	longest   int
+}
+
+func NewDeck(name string) *Deck {
+	return &Deck{
+		Name:      name,
+		Precision: 4,
+		rates:     make(map[string]*Rate),
+	}
+}
+
+// LoadDeck reads a csv deck of prefix,rate,connection,increments[,description]
+func LoadDeck(name, path string) (*Deck, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	deck := NewDeck(name)
+	if err := deck.Read(file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return deck, nil
+}
+
+func (deck *Deck) Read(input io.Reader) error {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	line := 0
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line++
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: too few fields", line)
+		}
+		if line == 1 && !digits(strings.TrimPrefix(fields[0], "+")) {
+			continue // header
+		}
+		rate, err := parseRate(fields)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		deck.Add(rate)
+	}
+}
+
+func (deck *Deck) Add(rate *Rate) {
+	deck.rates[rate.Prefix] = rate
+	if len(rate.Prefix) > deck.longest {
+		deck.longest = len(rate.Prefix)
+	}
+}
+
+func (deck *Deck) Len() int {
+	return len(deck.rates)
+}
+
+// Match finds the longest prefix rate for an e164 number
+func (deck *Deck) Match(number string) *Rate {
+	number = strings.TrimPrefix(number, "+")
+	size := len(number)
+	if size > deck.longest {
+		size = deck.longest
+	}
+	for ; size > 0; size-- {
+		if rate := deck.rates[number[:size]]; rate != nil {
+			return rate
+		}
+	}
+	return nil
+}
+
+// Billed rounds a duration up to the billing increments of a rate
+func (rate *Rate) Billed(duration time.Duration) int {
+	seconds := int(math.Ceil(duration.Seconds()))
+	if seconds <= 0 {
+		return 0
+	}
+	if seconds <= rate.Initial {
+		return rate.Initial
+	}
+	extra := seconds - rate.Initial
+	if rate.Increment > 1 {
+		extra = (extra + rate.Increment - 1) / rate.Increment * rate.Increment
+	}
+	return rate.Initial + extra
+}
+
+// Cost of a connected call of this duration, rounded to deck precision
+func (deck *Deck) Cost(rate *Rate, duration time.Duration) (float64, int) {
+	billed := rate.Billed(duration)
+	if billed == 0 {
+		return 0, 0
+	}
+	cost := rate.Connection + rate.PerMinute*float64(billed)/60
+	scale := math.Pow10(deck.Precision)
+	return math.Round(cost*scale) / scale, billed
+}
+
+func parseRate(fields []string) (*Rate, error) {
+	rate := &Rate{
+		Prefix:    strings.TrimPrefix(strings.TrimSpace(fields[0]), "+"),
+		Initial:   60,
+		Increment: 60,
+	}
+	if !digits(rate.Prefix) {
+		return nil, fmt.Errorf("invalid prefix %s", fields[0])
+	}
+
+	var err error
+	if rate.PerMinute, err = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64); err != nil {
+		return nil, err
+	}
+	if len(fields) > 2 && len(strings.TrimSpace(fields[2])) > 0 {
+		if rate.Connection, err = strconv.ParseFloat(strings.TrimSpace(fields[2]), 64); err != nil {
+			return nil, err
+		}
+	}
+	if len(fields) > 3 && len(strings.TrimSpace(fields[3])) > 0 {
+		initial, increment, ok := strings.Cut(strings.TrimSpace(fields[3]), "/")
+		if !ok {
+			increment = initial
+		}
+		if rate.Initial, err = strconv.Atoi(initial); err != nil {
+			return nil, err
+		}
+		if rate.Increment, err = strconv.Atoi(increment); err != nil {
+			return nil, err
+		}
+		if rate.Initial < 0 || rate.Increment < 1 {
+			return nil, fmt.Errorf("invalid increments %s", fields[3])
+		}
+	}
+	if len(fields) > 4 {
+		rate.Description = strings.TrimSpace(fields[4])
+	}
+	return rate, nil
+}
+
+func digits(text string) bool {
+	for _, b := range text {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return len(text) > 0
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rating
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadDeck(t *testing.T) {
+	deck, err := LoadDeck("carrier", "testdata/carrier.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deck.Len() != 5 {
+		t.Fatalf("Expected 5 rates, but got %d", deck.Len())
+	}
+
+	tests := map[string]string{
+		"+12125551234":  "1212",
+		"13105551234":   "1",
+		"+18005551234":  "1800",
+		"+442071234567": "44",
+		"+447700900123": "447",
+	}
+	for number, prefix := range tests {
+		rate := deck.Match(number)
+		if rate == nil || rate.Prefix != prefix {
+			t.Errorf("Expected prefix %s for %s, but got %+v", prefix, number, rate)
+		}
+	}
+	if rate := deck.Match("+33123456789"); rate != nil {
+		t.Errorf("Expected no rate, but got %+v", rate)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	deck, err := LoadDeck("carrier", "testdata/carrier.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		number   string
+		duration time.Duration
+		billed   int
+		cost     float64
+	}{
+		{"+13105551234", 0, 0, 0},
+		{"+13105551234", 200 * time.Millisecond, 6, 0.001},
+		{"+13105551234", 61 * time.Second, 66, 0.011},
+		{"+12125551234", 7 * time.Second, 12, 0.0016},
+		{"+442071234567", 10 * time.Second, 60, 0.035},
+		{"+442071234567", 61 * time.Second, 66, 0.0375},
+		{"+447700900123", 61 * time.Second, 120, 0.25},
+		{"+18005551234", 61500 * time.Millisecond, 62, 0},
+	}
+	for _, test := range tests {
+		rate := deck.Match(test.number)
+		cost, billed := deck.Cost(rate, test.duration)
+		if billed != test.billed || cost != test.cost {
+			t.Errorf("%s for %v: expected %d secs at %v, but got %d at %v", test.number, test.duration, test.billed, test.cost, billed, cost)
+		}
+	}
+}
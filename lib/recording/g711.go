@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package recording
+
+// Decoder expands an rtp payload into 16 bit linear samples
+type Decoder func(payload []byte, samples []int16) []int16
+
+// static payload types of g.711 in rfc 3551
+const (
+	PayloadPCMU = 0
+	PayloadPCMA = 8
+)
+
+var ulaw, alaw [256]int16
+
+func init() {
+	for code := 0; code < 256; code++ {
+		ulaw[code] = expandUlaw(byte(code))
+		alaw[code] = expandAlaw(byte(code))
+	}
+}
+
+// Decoding returns the decoder of a payload type, nil if not supported
+func Decoding(payload uint8) Decoder {
+	switch payload {
+	case PayloadPCMU:
+		return DecodeUlaw
+	case PayloadPCMA:
+		return DecodeAlaw
+	}
+	return nil
+}
+
+// DecodeUlaw expands g.711 mu-law
+func DecodeUlaw(payload []byte, samples []int16) []int16 {
+	for _, code := range payload {
+		samples = append(samples, ulaw[code])
+	}
+	return samples
+}
+
+// DecodeAlaw expands g.711 a-law
+func DecodeAlaw(payload []byte, samples []int16) []int16 {
+	for _, code := range payload {
+		samples = append(samples, alaw[code])
+	}
+	return samples
+}
+
+func expandUlaw(code byte) int16 {
+	code = ^code
+	magnitude := ((int16(code&0x0f) << 3) + 0x84) << ((code & 0x70) >> 4)
+	if code&0x80 != 0 {
+		return 0x84 - magnitude
+	}
+	return magnitude - 0x84
+}
+
+func expandAlaw(code byte) int16 {
+	code ^= 0x55
+	magnitude := int16(code&0x0f) << 4
+	switch segment := (code & 0x70) >> 4; segment {
+	case 0:
+		magnitude += 8
+	case 1:
+		magnitude += 0x108
+	default:
+		magnitude = (magnitude + 0x108) << (segment - 1)
+	}
+	if code&0x80 != 0 {
+		return magnitude
+	}
+	return -magnitude
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package recording
+
+import (
+	"encoding/binary"
+	"os"
+	"time"
+
+	"spycraft/lib/rtp"
+)
+
+// Rate is the sample rate of g.711 recordings
+const Rate = 8000
+
+const (
+	headerSize = 44
+	sampleSize = 2
+	resync     = 2 * Rate // samples a timestamp may stray from arrival
+)
+
+// Recorder writes rtp of two directions into a wav file as left and right
+// channels or mixed, placing samples by rtp timestamp so reordering and
+// silence gaps land where they belong
+type Recorder struct {
+	Stereo bool
+
+	file     *os.File
+	start    time.Time
+	channels int
+	samples  int64 // length in samples of each channel
+	tracks   [2]track
+	buffer   []byte
+	decoded  []int16
+}
+
+type track struct {
+	anchored bool
+	base     int64  // position of the anchor timestamp
+	anchor   uint32 // timestamp the track is anchored on
+}
+
+// Create starts a wav file for media that begins at start
+func Create(path string, stereo bool, start time.Time) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, err
+	}
+	recorder := &Recorder{
+		Stereo:   stereo,
+		file:     file,
+		start:    start,
+		channels: 1,
+	}
+	if stereo {
+		recorder.channels = 2
+	}
+	if err := recorder.header(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// Write places a packet of a channel, skipping payloads it cannot decode
+func (recorder *Recorder) Write(channel int, header rtp.Header, payload []byte, arrival time.Time) error {
+	decode := Decoding(header.Payload)
+	if decode == nil || channel < 0 || channel > 1 || len(payload) == 0 {
+		return nil
+	}
+	position := recorder.tracks[channel].place(header.Timestamp, int64(arrival.Sub(recorder.start))*Rate/int64(time.Second))
+	if position < 0 {
+		return nil
+	}
+	recorder.decoded = decode(payload, recorder.decoded[:0])
+	count := int64(len(recorder.decoded))
+	frame := int64(recorder.channels * sampleSize)
+	offset := headerSize + position*frame
+	size := int(count * frame)
+	if cap(recorder.buffer) < size {
+		recorder.buffer = make([]byte, size)
+	}
+	buffer := recorder.buffer[:size]
+	clear(buffer)
+	if position < recorder.samples {
+		if _, err := recorder.file.ReadAt(buffer[:min(int64(size), (recorder.samples-position)*frame)], offset); err != nil {
+			return err
+		}
+	}
+	for pos, sample := range recorder.decoded {
+		at := pos * int(frame)
+		if recorder.Stereo {
+			at += channel * sampleSize
+		} else {
+			sample = mix(int16(binary.LittleEndian.Uint16(buffer[at:])), sample)
+		}
+		binary.LittleEndian.PutUint16(buffer[at:], uint16(sample))
+	}
+	if _, err := recorder.file.WriteAt(buffer, offset); err != nil {
+		return err
+	}
+	recorder.samples = max(recorder.samples, position+count)
+	return nil
+}
+
+// Duration is the length recorded so far
+func (recorder *Recorder) Duration() time.Duration {
+	return time.Duration(recorder.samples) * time.Second / Rate
+}
+
+// Close finishes the wav header with the length recorded
+func (recorder *Recorder) Close() error {
+	err := recorder.header()
+	if closed := recorder.file.Close(); err == nil {
+		err = closed
+	}
+	return err
+}
+
+// place returns the sample position of a timestamp, anchoring the track
+// on arrival time at the start and whenever timestamps jump
+func (track *track) place(timestamp uint32, arrived int64) int64 {
+	if track.anchored {
+		position := track.base + int64(int32(timestamp-track.anchor))
+		if position-arrived < resync && arrived-position < resync {
+			return position
+		}
+	}
+	track.anchored = true
+	track.anchor = timestamp
+	track.base = arrived
+	return arrived
+}
+
+func (recorder *Recorder) header() error {
+	data := uint32(recorder.samples) * uint32(recorder.channels*sampleSize)
+	header := make([]byte, headerSize)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+data)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // pcm
+	binary.LittleEndian.PutUint16(header[22:], uint16(recorder.channels))
+	binary.LittleEndian.PutUint32(header[24:], Rate)
+	binary.LittleEndian.PutUint32(header[28:], Rate*uint32(recorder.channels*sampleSize))
+	binary.LittleEndian.PutUint16(header[32:], uint16(recorder.channels*sampleSize))
+	binary.LittleEndian.PutUint16(header[34:], 8*sampleSize)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], data)
+	_, err := recorder.file.WriteAt(header, 0)
+	return err
+}
+
+func mix(first, second int16) int16 {
+	sum := int32(first) + int32(second)
+	return int16(max(-32768, min(32767, sum)))
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"spycraft/lib/rtp"
+)
+
+func TestDecode(t *testing.T) {
+	samples := DecodeUlaw([]byte{0xff, 0x00, 0x80}, nil)
+	if samples[0] != 0 || samples[1] != -32124 || samples[2] != 32124 {
+		t.Fatalf("bad ulaw %v", samples)
+	}
+	samples = DecodeAlaw([]byte{0xd5, 0x55, 0xaa}, nil)
+	if samples[0] != 8 || samples[1] != -8 || samples[2] != 32256 {
+		t.Fatalf("bad alaw %v", samples)
+	}
+	if Decoding(18) != nil {
+		t.Fatal("g.729 should not decode")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "call.wav")
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder, err := Create(path, true, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := func(code byte) []byte {
+		return bytes.Repeat([]byte{code}, 160)
+	}
+	write := func(channel int, payload uint8, index int, code byte, late time.Duration) {
+		header := rtp.Header{Payload: payload, Sequence: uint16(index), Timestamp: 5000 + uint32(index)*160}
+		arrival := start.Add(time.Duration(index)*20*time.Millisecond + late)
+		if err := recorder.Write(channel, header, frame(code), arrival); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// left is mu-law with frame 2 reordered and frame 4 never sent,
+	// right is a-law starting 40ms late with comfort noise skipped
+	write(0, PayloadPCMU, 0, 0x80, 0)
+	write(0, PayloadPCMU, 1, 0x80, 0)
+	write(0, PayloadPCMU, 3, 0x80, 0)
+	write(0, PayloadPCMU, 2, 0x80, 30*time.Millisecond)
+	write(0, PayloadPCMU, 5, 0x80, 0)
+	write(1, PayloadPCMA, 2, 0xd5, 0)
+	write(1, 13, 3, 0x00, 0)
+	write(1, PayloadPCMA, 4, 0xd5, 5*time.Millisecond)
+	if recorder.Duration() != 120*time.Millisecond {
+		t.Fatalf("bad duration %v", recorder.Duration())
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" || binary.LittleEndian.Uint16(data[22:]) != 2 {
+		t.Fatal("bad wav header")
+	}
+	size := binary.LittleEndian.Uint32(data[40:])
+	if size != 6*160*4 || len(data) != headerSize+int(size) {
+		t.Fatalf("bad data size %d of %d", size, len(data))
+	}
+	sample := func(position, channel int) int16 {
+		return int16(binary.LittleEndian.Uint16(data[headerSize+position*4+channel*2:]))
+	}
+	for index, expected := range []int16{32124, 32124, 32124, 32124, 0, 32124} {
+		if sample(index*160+80, 0) != expected {
+			t.Fatalf("left frame %d is %d", index, sample(index*160+80, 0))
+		}
+	}
+	for index, expected := range []int16{0, 0, 8, 0, 8, 0} {
+		if sample(index*160+80, 1) != expected {
+			t.Fatalf("right frame %d is %d", index, sample(index*160+80, 1))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Header is the fixed rtp header of rfc 3550
+type Header struct {
+	Marker    bool
+	Payload   uint8
+	Sequence  uint16
+	Timestamp uint32
+	SSRC      uint32
+}
+
+var ErrPacket = errors.New("not an rtp packet")
+
+// Parse reads an rtp header and returns the payload after csrc and
+// extension headers with any padding removed
+func Parse(data []byte) (Header, []byte, error) {
+	var header Header
+	if len(data) < 12 || data[0]>>6 != 2 {
+		return header, nil, ErrPacket
+	}
+	header.Marker = data[1]&0x80 != 0
+	header.Payload = data[1] & 0x7f
+	if header.Payload >= 72 && header.Payload <= 76 {
+		return header, nil, ErrPacket // rtcp sent to the rtp port
+	}
+	header.Sequence = binary.BigEndian.Uint16(data[2:])
+	header.Timestamp = binary.BigEndian.Uint32(data[4:])
+	header.SSRC = binary.BigEndian.Uint32(data[8:])
+
+	offset := 12 + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 {
+		if len(data) < offset+4 {
+			return header, nil, ErrPacket
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return header, nil, ErrPacket
+	}
+	return header, data[offset:end], nil
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Quality measures one rtp stream as received on the wire
+type Quality struct {
+	Clock int // timestamp units per second
+
+	Packets    uint64
+	Duplicates uint64
+	OutOfOrder uint64
+	jitter     float64 // rfc 3550 interarrival jitter in timestamp units
+	maxDelta   time.Duration
+
+	started  bool
+	restart  bool   // a new source continues the stream
+	base     uint32 // extended first sequence
+	highest  uint32 // extended highest sequence
+	seen     uint64 // bitmask of sequences at and below highest
+	measured bool
+	transit  float64
+	epoch    time.Time
+	arrival  time.Time
+}
+
+// Report is the summary of a stream quality
+type Report struct {
+	Packets    uint64
+	Expected   uint64
+	Lost       uint64
+	Loss       float64 // percent of expected packets lost
+	OutOfOrder uint64
+	Duplicates uint64
+	Jitter     float64 // milliseconds
+	MaxDelta   float64 // milliseconds between arrivals
+	RFactor    float64
+	MOS        float64
+}
+
+// codec impairment and packet loss robustness of itu g.113 appendix i
+type impairment struct {
+	ie  float64
+	bpl float64
+}
+
+var impairments = map[string]impairment{
+	"PCMU": {0, 25.1},
+	"PCMA": {0, 25.1},
+	"G722": {0, 25.1},
+	"G729": {11, 19},
+	"G723": {15, 16.1},
+	"GSM":  {20, 10},
+	"ILBC": {11, 32},
+	"OPUS": {0, 20},
+}
+
+const (
+	sequenceWindow = 64
+	resyncDistance = 3000
+)
+
+// ClockRate is the rtp timestamp rate of a codec, g.722 being 8000 by rfc 3551
+func ClockRate(codec string) int {
+	switch strings.ToUpper(codec) {
+	case "OPUS":
+		return 48000
+	case "G7221", "L16":
+		return 16000
+	}
+	return 8000
+}
+
+func NewQuality(codec string) *Quality {
+	return &Quality{Clock: ClockRate(codec)}
+}
+
+// Add accounts for a packet and its arrival time, true if a duplicate
+func (quality *Quality) Add(header Header, arrival time.Time) bool {
+	quality.Packets++
+	sequence := uint32(header.Sequence)
+	if !quality.started {
+		quality.started = true
+		quality.base = sequence
+		quality.highest = sequence
+		quality.seen = 1
+		quality.arrived(header, arrival)
+		return false
+	}
+
+	if quality.restart {
+		quality.restart = false
+		quality.rebase(sequence)
+		quality.arrived(header, arrival)
+		return false
+	}
+
+	delta := int32(int16(header.Sequence - uint16(quality.highest)))
+	switch {
+	case delta > resyncDistance || delta < -resyncDistance:
+		// source restarted its sequence, count from the new one
+		quality.rebase(sequence)
+	case delta > 0:
+		quality.highest += uint32(delta)
+		if delta >= sequenceWindow {
+			quality.seen = 1
+		} else {
+			quality.seen = quality.seen<<uint(delta) | 1
+		}
+	case delta == 0:
+		quality.Duplicates++
+		return true
+	case -delta < sequenceWindow:
+		bit := uint64(1) << uint(-delta)
+		if quality.seen&bit != 0 {
+			quality.Duplicates++
+			return true
+		}
+		quality.seen |= bit
+		quality.OutOfOrder++
+	default:
+		quality.OutOfOrder++
+	}
+	quality.arrived(header, arrival)
+	return false
+}
+
+// Restart continues the stream from a new source, whose sequence and
+// timestamps start over with its next packet
+func (quality *Quality) Restart() {
+	if quality.started {
+		quality.restart = true
+		quality.measured = false
+	}
+}
+
+// Report summarizes the stream for a codec
+func (quality *Quality) Report(codec string) Report {
+	report := Report{
+		Packets:    quality.Packets,
+		OutOfOrder: quality.OutOfOrder,
+		Duplicates: quality.Duplicates,
+		MaxDelta:   milliseconds(quality.maxDelta),
+	}
+	if !quality.started {
+		return report
+	}
+	report.Expected = uint64(quality.highest - quality.base + 1)
+	received := quality.Packets - quality.Duplicates
+	if report.Expected > received {
+		report.Lost = report.Expected - received
+		report.Loss = 100 * float64(report.Lost) / float64(report.Expected)
+	}
+	if quality.Clock > 0 {
+		report.Jitter = 1000 * quality.jitter / float64(quality.Clock)
+	}
+	report.RFactor = RFactor(codec, report.Loss, report.Jitter)
+	report.MOS = MOS(report.RFactor)
+	return report
+}
+
+// RFactor estimates the e-model rating of itu g.107 from loss and jitter,
+// taking delay as a jitter buffer of twice the jitter over packetization
+func RFactor(codec string, loss, jitter float64) float64 {
+	codec = strings.ToUpper(codec)
+	impaired, found := impairments[codec]
+	if !found {
+		impaired = impairment{ie: 0, bpl: 10}
+	}
+	delay := 20 + 2*jitter
+	id := 0.024 * delay
+	if delay > 177.3 {
+		id += 0.11 * (delay - 177.3)
+	}
+	ie := impaired.ie + (95-impaired.ie)*loss/(loss+impaired.bpl)
+	return math.Max(0, math.Min(93.2, 93.2-id-ie))
+}
+
+// MOS maps an r-factor to an estimated mean opinion score
+func MOS(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + 7e-6*r*(r-60)*(100-r)
+}
+
+// rebase counts on from a new sequence, keeping packets expected so far
+func (quality *Quality) rebase(sequence uint32) {
+	expected := quality.highest - quality.base + 1
+	quality.highest = sequence | (quality.highest &^ 0xffff)
+	quality.base = quality.highest - expected
+	quality.seen = 1
+}
+
+func (quality *Quality) arrived(header Header, arrival time.Time) {
+	if quality.epoch.IsZero() {
+		quality.epoch = arrival
+	} else {
+		if delta := arrival.Sub(quality.arrival); delta > quality.maxDelta {
+			quality.maxDelta = delta
+		}
+	}
+	quality.arrival = arrival
+
+	// rfc 3550 a.8, transit in timestamp units
+	units := arrival.Sub(quality.epoch).Nanoseconds() * int64(quality.Clock) / int64(time.Second)
+	transit := float64(units - int64(header.Timestamp))
+	if quality.measured {
+		d := math.Abs(transit - quality.transit)
+		if d < float64(quality.Clock) { // ignore timestamp jumps
+			quality.jitter += (d - quality.jitter) / 16
+		}
+	}
+	quality.measured = true
+	quality.transit = transit
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuality(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	packet := func(sequence uint16) Header {
+		return Header{Sequence: sequence, Timestamp: 1000 + uint32(sequence-65500)*160}
+	}
+	arrival := func(sequence uint16) time.Time {
+		return start.Add(time.Duration(sequence-65500) * 20 * time.Millisecond)
+	}
+
+	// a clean stream wrapping its sequence
+	clean := NewQuality("PCMU")
+	for sequence := uint16(65500); sequence != 100; sequence++ {
+		clean.Add(packet(sequence), arrival(sequence))
+	}
+	report := clean.Report("PCMU")
+	if report.Expected != 136 || report.Lost != 0 || report.Jitter != 0 {
+		t.Fatalf("bad clean report %+v", report)
+	}
+	if report.MaxDelta != 20 || report.MOS < 4.3 {
+		t.Fatalf("bad clean score %+v", report)
+	}
+
+	// a new source restarts sequence and timestamps behind the old ones
+	clean.Restart()
+	for sequence := uint16(50); sequence != 100; sequence++ {
+		clean.Add(Header{Sequence: sequence, Timestamp: 500000 + uint32(sequence)*160}, arrival(sequence+136))
+	}
+	report = clean.Report("PCMU")
+	if report.Expected != 186 || report.Lost != 0 || report.Duplicates != 0 || report.Jitter != 0 {
+		t.Fatalf("bad restarted report %+v", report)
+	}
+
+	// lose five, duplicate one, and swap two
+	impaired := NewQuality("PCMU")
+	for sequence := uint16(65500); sequence != 100; sequence++ {
+		switch sequence {
+		case 10, 11, 12, 13, 14:
+			continue
+		case 20:
+			impaired.Add(packet(21), arrival(20))
+			impaired.Add(packet(20), arrival(21))
+			continue
+		case 21:
+			continue
+		case 30:
+			if impaired.Add(packet(30), arrival(30)) {
+				t.Fatal("first packet reported duplicate")
+			}
+			if !impaired.Add(packet(30), arrival(30)) {
+				t.Fatal("duplicate packet not reported")
+			}
+			continue
+		}
+		impaired.Add(packet(sequence), arrival(sequence))
+	}
+	report = impaired.Report("PCMU")
+	if report.Lost != 5 || report.Duplicates != 1 || report.OutOfOrder != 1 {
+		t.Fatalf("bad impaired report %+v", report)
+	}
+	if report.Jitter <= 0 || report.MOS >= clean.Report("PCMU").MOS {
+		t.Fatalf("bad impaired score %+v", report)
+	}
+
+	if MOS(RFactor("G729", 0, 0)) >= MOS(RFactor("PCMA", 0, 0)) {
+		t.Fatal("g.729 should score below g.711")
+	}
+	if ClockRate("opus") != 48000 || ClockRate("G722") != 8000 {
+		t.Fatal("bad clock rates")
+	}
+}
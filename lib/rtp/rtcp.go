@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// rtcp packet types of rfc 3550 and rfc 3611
+const (
+	SenderReport   = 200
+	ReceiverReport = 201
+	SourceDesc     = 202
+	Goodbye        = 203
+	ExtendedReport = 207
+
+	voipMetricsBlock = 7
+	unavailable      = 127
+)
+
+// Sender is the sender info of a sender report
+type Sender struct {
+	NTP       uint64
+	Timestamp uint32
+	Packets   uint32
+	Octets    uint32
+}
+
+// Block is a reception report about one source
+type Block struct {
+	SSRC     uint32
+	Fraction uint8 // fraction lost since last report, of 256
+	Lost     int32 // cumulative packets lost
+	Highest  uint32
+	Jitter   uint32 // timestamp units
+	LSR      uint32 // middle of ntp time of last sender report
+	DLSR     uint32 // delay since last sender report, 1/65536 sec
+}
+
+// VoIPMetrics is an rtcp-xr voip metrics report block
+type VoIPMetrics struct {
+	SSRC        uint32
+	LossRate    uint8 // of 256
+	DiscardRate uint8 // of 256
+	RoundTrip   uint16
+	EndDelay    uint16
+	RFactor     uint8 // 127 if unavailable
+	MOSLQ       uint8 // times 10, 127 if unavailable
+	MOSCQ       uint8 // times 10, 127 if unavailable
+	JitterMax   uint16
+}
+
+// Compound is what a compound rtcp packet reports
+type Compound struct {
+	SSRC   uint32  // of the sender of the report
+	Sender *Sender // if a sender report
+	Blocks []Block
+	CNAME  string
+	Bye    bool
+	Reason string
+	VoIP   []VoIPMetrics
+}
+
+var ErrRTCP = errors.New("not an rtcp packet")
+
+// ParseRTCP reads the packets of a compound rtcp datagram
+func ParseRTCP(data []byte) (*Compound, error) {
+	if len(data) < 8 || data[0]>>6 != 2 || data[1] < SenderReport || data[1] > ExtendedReport {
+		return nil, ErrRTCP
+	}
+	compound := &Compound{}
+	for len(data) >= 4 {
+		if data[0]>>6 != 2 {
+			return nil, ErrRTCP
+		}
+		count := int(data[0] & 0x1f)
+		kind := data[1]
+		length := 4 * (int(binary.BigEndian.Uint16(data[2:])) + 1)
+		if length > len(data) {
+			return nil, ErrRTCP
+		}
+		body := data[4:length]
+		if data[0]&0x20 != 0 && len(body) > 0 {
+			padding := int(body[len(body)-1])
+			if padding > len(body) {
+				return nil, ErrRTCP
+			}
+			body = body[:len(body)-padding]
+		}
+		data = data[length:]
+
+		var err error
+		switch kind {
+		case SenderReport:
+			err = compound.sender(body, count)
+		case ReceiverReport:
+			err = compound.receiver(body, count)
+		case SourceDesc:
+			compound.describe(body, count)
+		case Goodbye:
+			compound.goodbye(body, count)
+		case ExtendedReport:
+			err = compound.extended(body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compound, nil
+}
+
+// Middle is the middle 32 bits of an ntp time a receiver reports as lsr
+func (sender *Sender) Middle() uint32 {
+	return uint32(sender.NTP >> 16)
+}
+
+// MOS is the listening quality score, zero if unavailable
+func (metrics *VoIPMetrics) MOS() float64 {
+	if metrics.MOSLQ == unavailable || metrics.MOSLQ == 0 {
+		return 0
+	}
+	return float64(metrics.MOSLQ) / 10
+}
+
+// Rating is the reported r-factor, zero if unavailable
+func (metrics *VoIPMetrics) Rating() float64 {
+	if metrics.RFactor == unavailable {
+		return 0
+	}
+	return float64(metrics.RFactor)
+}
+
+func (compound *Compound) sender(body []byte, count int) error {
+	if len(body) < 24 {
+		return ErrRTCP
+	}
+	compound.SSRC = binary.BigEndian.Uint32(body)
+	compound.Sender = &Sender{
+		NTP:       binary.BigEndian.Uint64(body[4:]),
+		Timestamp: binary.BigEndian.Uint32(body[12:]),
+		Packets:   binary.BigEndian.Uint32(body[16:]),
+		Octets:    binary.BigEndian.Uint32(body[20:]),
+	}
+	return compound.blocks(body[24:], count)
+}
+
+func (compound *Compound) receiver(body []byte, count int) error {
+	if len(body) < 4 {
+		return ErrRTCP
+	}
+	compound.SSRC = binary.BigEndian.Uint32(body)
+	return compound.blocks(body[4:], count)
+}
+
+func (compound *Compound) blocks(body []byte, count int) error {
+	if len(body) < 24*count {
+		return ErrRTCP
+	}
+	for pos := 0; pos < count; pos++ {
+		block := body[24*pos:]
+		lost := int32(binary.BigEndian.Uint32(block[4:])<<8) >> 8 // signed 24 bits
+		compound.Blocks = append(compound.Blocks, Block{
+			SSRC:     binary.BigEndian.Uint32(block),
+			Fraction: block[4],
+			Lost:     lost,
+			Highest:  binary.BigEndian.Uint32(block[8:]),
+			Jitter:   binary.BigEndian.Uint32(block[12:]),
+			LSR:      binary.BigEndian.Uint32(block[16:]),
+			DLSR:     binary.BigEndian.Uint32(block[20:]),
+		})
+	}
+	return nil
+}
+
+func (compound *Compound) describe(body []byte, count int) {
+	for ; count > 0 && len(body) >= 4; count-- {
+		ssrc := binary.BigEndian.Uint32(body)
+		pos := 4
+		for pos < len(body) && body[pos] != 0 {
+			if pos+2 > len(body) || pos+2+int(body[pos+1]) > len(body) {
+				return
+			}
+			item, text := body[pos], body[pos+2:pos+2+int(body[pos+1])]
+			if item == 1 && (ssrc == compound.SSRC || len(compound.CNAME) == 0) {
+				compound.CNAME = string(text)
+			}
+			pos += 2 + len(text)
+		}
+		pos = (pos + 4) &^ 3 // end item and padding to a word
+		if pos > len(body) {
+			return
+		}
+		body = body[pos:]
+	}
+}
+
+func (compound *Compound) goodbye(body []byte, count int) {
+	compound.Bye = true
+	if len(body) < 4*count {
+		return
+	}
+	body = body[4*count:]
+	if len(body) > 0 && int(body[0]) < len(body) {
+		compound.Reason = string(body[1 : 1+int(body[0])])
+	}
+}
+
+func (compound *Compound) extended(body []byte) error {
+	if len(body) < 4 {
+		return ErrRTCP
+	}
+	if compound.SSRC == 0 {
+		compound.SSRC = binary.BigEndian.Uint32(body)
+	}
+	body = body[4:]
+	for len(body) >= 4 {
+		kind := body[0]
+		length := 4 * (int(binary.BigEndian.Uint16(body[2:])) + 1)
+		if length > len(body) {
+			return ErrRTCP
+		}
+		block := body[4:length]
+		body = body[length:]
+		if kind != voipMetricsBlock || len(block) < 32 {
+			continue
+		}
+		compound.VoIP = append(compound.VoIP, VoIPMetrics{
+			SSRC:        binary.BigEndian.Uint32(block),
+			LossRate:    block[4],
+			DiscardRate: block[5],
+			RoundTrip:   binary.BigEndian.Uint16(block[12:]),
+			EndDelay:    binary.BigEndian.Uint16(block[14:]),
+			RFactor:     block[20],
+			MOSLQ:       block[22],
+			MOSCQ:       block[23],
+			JitterMax:   binary.BigEndian.Uint16(block[28:]),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func rtcpPacket(kind, count uint8, body []byte) []byte {
+	packet := []byte{0x80 | count, kind, 0, 0}
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(body)/4))
+	return append(packet, body...)
+}
+
+func TestParseRTCP(t *testing.T) {
+	sr := make([]byte, 48)
+	binary.BigEndian.PutUint32(sr, 0x1111)
+	binary.BigEndian.PutUint64(sr[4:], 0x1234345600009999)
+	binary.BigEndian.PutUint32(sr[16:], 500)
+	binary.BigEndian.PutUint32(sr[24:], 0x2222)                        // block source
+	binary.BigEndian.PutUint32(sr[28:], 0x40fffffe)                    // fraction 64, lost -2
+	binary.BigEndian.PutUint32(sr[36:], 80)                            // jitter
+	binary.BigEndian.PutUint32(sr[40:], 0x34560000)                    // lsr
+	binary.BigEndian.PutUint32(sr[44:], 0x00008000)                    // half a second
+	sdes := []byte{0, 0, 0x11, 0x11, 1, 5, 'a', '@', 'b', '.', 'c', 0} // cname
+	bye := []byte{0, 0, 0x11, 0x11, 4, 'd', 'o', 'n', 'e', 0, 0, 0}
+
+	xr := make([]byte, 40)
+	binary.BigEndian.PutUint32(xr, 0x1111)
+	xr[4] = voipMetricsBlock
+	binary.BigEndian.PutUint16(xr[6:], 8)
+	binary.BigEndian.PutUint32(xr[8:], 0x2222)
+	xr[12] = 13                             // loss rate
+	binary.BigEndian.PutUint16(xr[20:], 42) // round trip
+	xr[28] = 88                             // r factor
+	xr[30] = 41                             // mos-lq
+
+	data := rtcpPacket(SenderReport, 1, sr)
+	data = append(data, rtcpPacket(SourceDesc, 1, sdes)...)
+	data = append(data, rtcpPacket(ExtendedReport, 0, xr)...)
+	data = append(data, rtcpPacket(Goodbye, 1, bye)...)
+
+	compound, err := ParseRTCP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compound.SSRC != 0x1111 || compound.Sender == nil || compound.Sender.Packets != 500 || compound.Sender.Middle() != 0x34560000 {
+		t.Fatalf("bad sender %+v %+v", compound, compound.Sender)
+	}
+	if len(compound.Blocks) != 1 {
+		t.Fatalf("expected 1 block, got %d", len(compound.Blocks))
+	}
+	block := compound.Blocks[0]
+	if block.SSRC != 0x2222 || block.Fraction != 64 || block.Lost != -2 || block.Jitter != 80 || block.DLSR != 0x8000 {
+		t.Fatalf("bad block %+v", block)
+	}
+	if compound.CNAME != "a@b.c" || !compound.Bye || compound.Reason != "done" {
+		t.Fatalf("bad sdes or bye %+v", compound)
+	}
+	if len(compound.VoIP) != 1 {
+		t.Fatal("missing voip metrics")
+	}
+	voip := compound.VoIP[0]
+	if voip.SSRC != 0x2222 || voip.LossRate != 13 || voip.RoundTrip != 42 || voip.Rating() != 88 || voip.MOS() != 4.1 {
+		t.Fatalf("bad voip metrics %+v", voip)
+	}
+
+	if _, err = ParseRTCP([]byte{0x80, 0x00, 0, 1, 0, 0, 0, 0}); err == nil {
+		t.Fatal("rtp accepted as rtcp")
+	}
+	if _, err = ParseRTCP(rtcpPacket(ReceiverReport, 2, sr[:28])); err == nil {
+		t.Fatal("short receiver report accepted")
+	}
+}
@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Idle is a filter that matches no media, used when nothing is bound
+const Idle = "udp port 0"
+
+// Endpoint is a media address advertised in sdp
+type Endpoint struct {
+	IP   net.IP
+	Port uint16
+}
+
+// Flow associates a media packet with the leg that advertised its endpoint
+type Flow struct {
+	Leg      string // leg bound to the endpoint
+	Incoming bool   // sent toward the local side of the leg
+	RTCP     bool   // sent to or from the odd rtcp port
+}
+
+// Range is an inclusive udp port range
+type Range struct {
+	Low  uint16
+	High uint16
+}
+
+type binding struct {
+	leg   string
+	local bool
+}
+
+// Table maps media endpoints of active legs, shared between the packet
+// and message pipelines, an endpoint may be bound to several legs such
+// as both legs of a b2bua that passes sdp through
+type Table struct {
+	lock      sync.RWMutex
+	endpoints map[string][]binding
+	legs      map[string]*[2]Endpoint // local and remote endpoint of a leg
+	version   uint64
+}
+
+func NewTable() *Table {
+	return &Table{
+		endpoints: make(map[string][]binding),
+		legs:      make(map[string]*[2]Endpoint),
+	}
+}
+
+func (endpoint Endpoint) String() string {
+	return fmt.Sprintf("%v/%v", endpoint.IP, endpoint.Port)
+}
+
+// Valid tests if an endpoint can receive media
+func (endpoint Endpoint) Valid() bool {
+	return endpoint.Port > 0 && endpoint.IP != nil && !endpoint.IP.IsUnspecified()
+}
+
+// Bind sets the local or remote endpoint of a leg, replacing any before
+func (table *Table) Bind(leg string, endpoint Endpoint, local bool) {
+	side := 1
+	if local {
+		side = 0
+	}
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	pair := table.legs[leg]
+	if pair == nil {
+		pair = &[2]Endpoint{}
+		table.legs[leg] = pair
+	}
+	previous := pair[side]
+	if previous.Port == endpoint.Port && previous.IP.Equal(endpoint.IP) {
+		return
+	}
+	if previous.Valid() {
+		table.unbind(leg, previous)
+	}
+	pair[side] = Endpoint{}
+	if endpoint.Valid() {
+		pair[side] = endpoint
+		key := endpoint.String()
+		table.endpoints[key] = append(table.endpoints[key], binding{leg: leg, local: local})
+	}
+	table.version++
+}
+
+// Release removes the endpoints of a leg
+func (table *Table) Release(leg string) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	pair := table.legs[leg]
+	if pair == nil {
+		return
+	}
+	delete(table.legs, leg)
+	for _, endpoint := range pair {
+		if endpoint.Valid() {
+			table.unbind(leg, endpoint)
+		}
+	}
+	table.version++
+}
+
+// Endpoints returns the local and remote endpoint bound for a leg
+func (table *Table) Endpoints(leg string) (local, remote Endpoint) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	if pair := table.legs[leg]; pair != nil {
+		return pair[0], pair[1]
+	}
+	return
+}
+
+// Len is the number of endpoints bound
+func (table *Table) Len() int {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	return len(table.endpoints)
+}
+
+// Version changes whenever endpoints are bound or released
+func (table *Table) Version() uint64 {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	return table.version
+}
+
+// Match finds the flows of a udp packet by its target, then its source,
+// one for each leg bound to the endpoint
+func (table *Table) Match(sourceIP net.IP, sourcePort uint16, targetIP net.IP, targetPort uint16) []Flow {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	var flows []Flow
+	if bindings, rtcp := table.find(targetIP, targetPort); len(bindings) > 0 {
+		for _, bound := range bindings {
+			flows = append(flows, Flow{Leg: bound.leg, Incoming: bound.local, RTCP: rtcp})
+		}
+		return flows
+	}
+	bindings, rtcp := table.find(sourceIP, sourcePort)
+	for _, bound := range bindings {
+		flows = append(flows, Flow{Leg: bound.leg, Incoming: !bound.local, RTCP: rtcp})
+	}
+	return flows
+}
+
+// Filter builds a bpf rule for bound endpoints and their rtcp ports,
+// falling back to port ranges when more than limit are bound, and never
+// matching the signaling port already captured for sip
+func (table *Table) Filter(limit int, ranges []Range, signaling uint16) string {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	if len(table.endpoints) == 0 {
+		return Idle
+	}
+
+	endpoints := make([]Endpoint, 0, len(table.endpoints))
+	for _, pair := range table.legs {
+		for _, endpoint := range pair {
+			if endpoint.Valid() {
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if order := bytes.Compare(endpoints[i].IP.To16(), endpoints[j].IP.To16()); order != 0 {
+			return order < 0
+		}
+		return endpoints[i].Port < endpoints[j].Port
+	})
+
+	var clauses []string
+	covered := false
+	if limit > 0 && len(endpoints) > limit {
+		if len(ranges) == 0 {
+			low, high := endpoints[0].Port, endpoints[0].Port
+			for _, endpoint := range endpoints {
+				low = min(low, endpoint.Port)
+				high = max(high, endpoint.Port)
+			}
+			ranges = []Range{{Low: low, High: high}}
+		}
+		for _, span := range ranges {
+			high := span.High
+			if high < 65535 {
+				high++ // rtcp of the highest port
+			}
+			covered = covered || (signaling >= span.Low && signaling <= high)
+			clauses = append(clauses, fmt.Sprintf("portrange %d-%d", span.Low, high))
+		}
+	} else {
+		for pos, endpoint := range endpoints {
+			if pos > 0 && endpoints[pos-1].Port == endpoint.Port && endpoints[pos-1].IP.Equal(endpoint.IP) {
+				continue
+			}
+			covered = covered || signaling == endpoint.Port || signaling == endpoint.Port+1
+			host := "host"
+			if endpoint.IP.To4() == nil {
+				host = "ip6 host"
+			}
+			clauses = append(clauses, fmt.Sprintf("(%s %v and portrange %d-%d)", host, endpoint.IP, endpoint.Port, endpoint.Port+1))
+		}
+	}
+	filter := "udp and (" + strings.Join(clauses, " or ") + ")"
+	if signaling > 0 && covered {
+		filter += fmt.Sprintf(" and not port %d", signaling)
+	}
+	return filter
+}
+
+// ParseRanges reads comma separated port ranges such as 10000-20000
+func ParseRanges(text string) ([]Range, error) {
+	var ranges []Range
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		low, high, found := strings.Cut(item, "-")
+		if !found {
+			high = low
+		}
+		first, err := strconv.ParseUint(strings.TrimSpace(low), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("port range %s: %w", item, err)
+		}
+		last, err := strconv.ParseUint(strings.TrimSpace(high), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("port range %s: %w", item, err)
+		}
+		if first == 0 || last < first {
+			return nil, fmt.Errorf("port range %s: invalid", item)
+		}
+		ranges = append(ranges, Range{Low: uint16(first), High: uint16(last)})
+	}
+	return ranges, nil
+}
+
+func (table *Table) find(ip net.IP, port uint16) ([]binding, bool) {
+	key := Endpoint{IP: ip, Port: port}.String()
+	if bindings, found := table.endpoints[key]; found {
+		return bindings, false
+	}
+	if port&1 == 1 {
+		key = Endpoint{IP: ip, Port: port - 1}.String()
+		if bindings, found := table.endpoints[key]; found {
+			return bindings, true
+		}
+	}
+	return nil, false
+}
+
+// unbind removes only the binding of a leg, others may share the endpoint
+func (table *Table) unbind(leg string, endpoint Endpoint) {
+	key := endpoint.String()
+	bindings := table.endpoints[key]
+	for pos, bound := range bindings {
+		if bound.leg == leg {
+			bindings = append(bindings[:pos], bindings[pos+1:]...)
+			break
+		}
+	}
+	if len(bindings) == 0 {
+		delete(table.endpoints, key)
+	} else {
+		table.endpoints[key] = bindings
+	}
+}
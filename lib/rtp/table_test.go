@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package rtp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	table := NewTable()
+	if table.Filter(4, nil, 5060) != Idle {
+		t.Fatal("empty table should be idle")
+	}
+
+	local := Endpoint{IP: net.ParseIP("10.0.0.1"), Port: 10000}
+	remote := Endpoint{IP: net.ParseIP("192.168.1.5"), Port: 4000}
+	table.Bind("leg1", local, true)
+	table.Bind("leg1", remote, false)
+	if table.Len() != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", table.Len())
+	}
+
+	flows := table.Match(remote.IP, remote.Port, local.IP, local.Port)
+	if len(flows) != 1 || flows[0].Leg != "leg1" || !flows[0].Incoming || flows[0].RTCP {
+		t.Fatalf("bad incoming flow %+v", flows)
+	}
+	flows = table.Match(local.IP, local.Port, remote.IP, remote.Port)
+	if len(flows) != 1 || flows[0].Incoming {
+		t.Fatalf("bad outgoing flow %+v", flows)
+	}
+	flows = table.Match(remote.IP, 4001, local.IP, 10001)
+	if len(flows) != 1 || !flows[0].RTCP || !flows[0].Incoming {
+		t.Fatalf("bad rtcp flow %+v", flows)
+	}
+	if flows = table.Match(net.ParseIP("10.9.9.9"), 5000, local.IP, 10002); len(flows) != 0 {
+		t.Fatal("unbound endpoint matched")
+	}
+
+	filter := table.Filter(4, nil, 5060)
+	expected := "udp and ((host 10.0.0.1 and portrange 10000-10001) or (host 192.168.1.5 and portrange 4000-4001))"
+	if filter != expected {
+		t.Fatalf("bad filter %q", filter)
+	}
+	if filter = table.Filter(1, nil, 5060); filter != "udp and (portrange 4000-10001) and not port 5060" {
+		t.Fatalf("bad fallback filter %q", filter)
+	}
+	ranges, err := ParseRanges("10000-20000, 30000")
+	if err != nil || len(ranges) != 2 || ranges[1].High != 30000 {
+		t.Fatalf("bad ranges %v %v", ranges, err)
+	}
+	if filter = table.Filter(1, ranges, 5060); filter != "udp and (portrange 10000-20001 or portrange 30000-30001)" {
+		t.Fatalf("bad range filter %q", filter)
+	}
+
+	version := table.Version()
+	table.Bind("leg1", Endpoint{IP: net.IPv4zero, Port: 4000}, false) // hold
+	if table.Version() == version || table.Len() != 1 {
+		t.Fatal("hold should unbind remote endpoint")
+	}
+	table.Release("leg1")
+	if table.Len() != 0 || table.Filter(4, nil, 5060) != Idle {
+		t.Fatal("release should unbind leg")
+	}
+}
+
+func TestTableShared(t *testing.T) {
+	table := NewTable()
+	phone := Endpoint{IP: net.ParseIP("192.168.1.5"), Port: 4000}
+	gateway := Endpoint{IP: net.ParseIP("10.0.0.9"), Port: 20000}
+
+	// a b2bua passing sdp through binds the same endpoints to both legs
+	table.Bind("inbound", gateway, true)
+	table.Bind("inbound", phone, false)
+	table.Bind("outbound", phone, true)
+	table.Bind("outbound", gateway, false)
+	if table.Len() != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", table.Len())
+	}
+	flows := table.Match(gateway.IP, gateway.Port, phone.IP, phone.Port)
+	if len(flows) != 2 || flows[0].Leg != "inbound" || flows[0].Incoming || flows[1].Leg != "outbound" || !flows[1].Incoming {
+		t.Fatalf("bad shared flows %+v", flows)
+	}
+
+	table.Release("inbound")
+	flows = table.Match(gateway.IP, gateway.Port, phone.IP, phone.Port)
+	if table.Len() != 2 || len(flows) != 1 || flows[0].Leg != "outbound" {
+		t.Fatalf("release should keep other leg, got %+v", flows)
+	}
+	table.Release("outbound")
+	if table.Len() != 0 {
+		t.Fatal("release should unbind shared endpoints")
+	}
+}
+
+func TestParse(t *testing.T) {
+	packet := []byte{0x80, 0x80, 0x01, 0x02, 0, 0, 0x03, 0x20, 0xde, 0xad, 0xbe, 0xef, 0xff, 0xfe}
+	header, payload, err := Parse(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.Marker || header.Payload != 0 || header.Sequence != 258 || header.Timestamp != 800 || header.SSRC != 0xdeadbeef {
+		t.Fatalf("bad header %+v", header)
+	}
+	if len(payload) != 2 || payload[0] != 0xff {
+		t.Fatalf("bad payload %v", payload)
+	}
+	if _, _, err = Parse([]byte{0x80, 0xc8, 0, 6, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Fatal("rtcp accepted as rtp")
+	}
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package trunk
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Class string
+
+const (
+	Unknown   Class = ""
+	Carrier   Class = "carrier"
+	Extension Class = "extension"
+	Peer      Class = "peer" // internal peer such as a pbx or sbc
+)
+
+// Trunk is a named peer matched by address, port, realm, or user agent
+type Trunk struct {
+	Name     string   `ini:"-"`
+	Class    Class    `ini:"class"`
+	Networks []string `ini:"networks" delim:","` // cidr or address
+	Ports    []string `ini:"ports" delim:","`    // port or low-high range
+	Realms   []string `ini:"realms" delim:","`   // uri host or *.domain
+	Agents   []string `ini:"agents" delim:","`   // user agent patterns
+	Channels int      `ini:"channels"`           // licensed or capacity limit
+
+	networks []*net.IPNet
+	ports    [][2]uint16
+	agents   []*regexp.Regexp
+}
+
+// Table matches legs to trunks in configured order
+type Table struct {
+	trunks []*Trunk
+}
+
+// Compile validates and prepares a trunk for matching
+func (trunk *Trunk) Compile() error {
+	switch trunk.Class {
+	case Unknown, Carrier, Extension, Peer:
+	default:
+		return fmt.Errorf("trunk %s: invalid class %s", trunk.Name, trunk.Class)
+	}
+
+	trunk.networks = nil
+	for _, network := range trunk.Networks {
+		network = strings.TrimSpace(network)
+		if len(network) == 0 {
+			continue
+		}
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return fmt.Errorf("trunk %s: invalid address %s", trunk.Name, network)
+			}
+			if ip.To4() != nil {
+				network += "/32"
+			} else {
+				network += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(network)
+		if err != nil {
+			return fmt.Errorf("trunk %s: %v", trunk.Name, err)
+		}
+		trunk.networks = append(trunk.networks, cidr)
+	}
+
+	trunk.ports = nil
+	for _, port := range trunk.Ports {
+		port = strings.TrimSpace(port)
+		if len(port) == 0 {
+			continue
+		}
+		low, high, ok := strings.Cut(port, "-")
+		if !ok {
+			high = low
+		}
+		first, err := strconv.ParseUint(low, 10, 16)
+		if err != nil {
+			return fmt.Errorf("trunk %s: invalid port %s", trunk.Name, port)
+		}
+		last, err := strconv.ParseUint(high, 10, 16)
+		if err != nil || last < first {
+			return fmt.Errorf("trunk %s: invalid port %s", trunk.Name, port)
+		}
+		trunk.ports = append(trunk.ports, [2]uint16{uint16(first), uint16(last)})
+	}
+
+	trunk.agents = nil
+	for _, agent := range trunk.Agents {
+		agent = strings.TrimSpace(agent)
+		if len(agent) == 0 {
+			continue
+		}
+		pattern, err := regexp.Compile("(?i)" + agent)
+		if err != nil {
+			return fmt.Errorf("trunk %s: %v", trunk.Name, err)
+		}
+		trunk.agents = append(trunk.agents, pattern)
+	}
+	return nil
+}
+
+// Add compiles and appends a trunk to the table
+func (table *Table) Add(trunk *Trunk) error {
+	if err := trunk.Compile(); err != nil {
+		return err
+	}
+	table.trunks = append(table.trunks, trunk)
+	return nil
+}
+
+func (table *Table) Len() int {
+	return len(table.trunks)
+}
+
+// Find returns a trunk by name
+func (table *Table) Find(name string) *Trunk {
+	for _, trunk := range table.trunks {
+		if trunk.Name == name {
+			return trunk
+		}
+	}
+	return nil
+}
+
+// Match finds the first trunk all of whose given criteria fit
+func (table *Table) Match(ip net.IP, port uint16, realm, agent string) *Trunk {
+	for _, trunk := range table.trunks {
+		if trunk.Matches(ip, port, realm, agent) {
+			return trunk
+		}
+	}
+	return nil
+}
+
+// Matches tests each configured criteria, a trunk with none never matches
+func (trunk *Trunk) Matches(ip net.IP, port uint16, realm, agent string) bool {
+	if len(trunk.networks) == 0 && len(trunk.ports) == 0 && len(trunk.Realms) == 0 && len(trunk.agents) == 0 {
+		return false
+	}
+	if len(trunk.networks) > 0 && !trunk.matchNetwork(ip) {
+		return false
+	}
+	if len(trunk.ports) > 0 && !trunk.matchPort(port) {
+		return false
+	}
+	if len(trunk.Realms) > 0 && !trunk.matchRealm(realm) {
+		return false
+	}
+	if len(trunk.agents) > 0 && !trunk.matchAgent(agent) {
+		return false
+	}
+	return true
+}
+
+func (trunk *Trunk) matchNetwork(ip net.IP) bool {
+	for _, network := range trunk.networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (trunk *Trunk) matchPort(port uint16) bool {
+	for _, span := range trunk.ports {
+		if port >= span[0] && port <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (trunk *Trunk) matchRealm(realm string) bool {
+	if len(realm) == 0 {
+		return false
+	}
+	for _, name := range trunk.Realms {
+		name = strings.TrimSpace(name)
+		if suffix, wild := strings.CutPrefix(name, "*."); wild {
+			if strings.HasSuffix(strings.ToLower(realm), "."+strings.ToLower(suffix)) {
+				return true
+			}
+		} else if strings.EqualFold(name, realm) {
+			return true
+		}
+	}
+	return false
+}
+
+func (trunk *Trunk) matchAgent(agent string) bool {
+	for _, pattern := range trunk.agents {
+		if len(agent) > 0 && pattern.MatchString(agent) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: AGPL-3.0-or-later
+// Copyright (C) 2025 David Sugar <tychosoft@gmail.com>
+
+package trunk
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	table := &Table{}
+	trunks := []*Trunk{
+		{Name: "carrier", Class: Carrier, Networks: []string{"203.0.113.0/24", "198.51.100.7"}, Ports: []string{"5060"}},
+		{Name: "pbx", Class: Peer, Realms: []string{"pbx.example.com"}},
+		{Name: "phones", Class: Extension, Networks: []string{"10.0.0.0/8"}, Agents: []string{"^yealink", "polycom"}},
+		{Name: "office", Class: Extension, Realms: []string{"*.example.com"}},
+	}
+	for _, trunk := range trunks {
+		if err := table.Add(trunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		ip    string
+		port  uint16
+		realm string
+		agent string
+		name  string
+	}{
+		{"203.0.113.9", 5060, "", "", "carrier"},
+		{"198.51.100.7", 5060, "", "", "carrier"},
+		{"203.0.113.9", 5080, "", "", ""},
+		{"10.1.2.3", 5060, "pbx.example.com", "Asterisk", "pbx"},
+		{"10.1.2.3", 5062, "", "Yealink SIP-T46S", "phones"},
+		{"10.1.2.3", 5062, "", "Grandstream", ""},
+		{"192.0.2.1", 5060, "east.example.com", "", "office"},
+		{"192.0.2.1", 5060, "example.com", "", ""},
+	}
+	for _, test := range tests {
+		found := table.Match(net.ParseIP(test.ip), test.port, test.realm, test.agent)
+		name := ""
+		if found != nil {
+			name = found.Name
+		}
+		if name != test.name {
+			t.Errorf("%s:%d %s %s: expected %q, but got %q", test.ip, test.port, test.realm, test.agent, test.name, name)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	for _, trunk := range []*Trunk{
+		{Name: "bad", Class: "vendor"},
+		{Name: "bad", Networks: []string{"10.0.0.0/33"}},
+		{Name: "bad", Ports: []string{"5080-5060"}},
+		{Name: "bad", Agents: []string{"("}},
+	} {
+		if err := trunk.Compile(); err == nil {
+			t.Errorf("Expected error for %+v", trunk)
+		}
+	}
+}